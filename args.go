@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitArgs splits a command line on whitespace. Single or double quotes group
+// words into one argument and a backslash escapes the next character outside
+// single quotes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("Error: Unterminated quote or escape.")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// extractFlag removes a "--name value" pair from the arguments and returns its value
+func extractFlag(args []string, name string) (string, []string, error) {
+	for i, arg := range args {
+		if arg != name {
+			continue
+		}
+		if i+1 >= len(args) {
+			return "", args, fmt.Errorf("Error: The %s flag needs a value.", name)
+		}
+		rest := append(append([]string{}, args[:i]...), args[i+2:]...)
+		return args[i+1], rest, nil
+	}
+	return "", args, nil
+}
+
+// rawArgsAfter returns the line after its first n whitespace separated words, quotes included
+func rawArgsAfter(line string, n int) string {
+	rest := strings.TrimLeft(line, " \t")
+	for i := 0; i < n; i++ {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			return ""
+		}
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	return rest
+}
+
+// extractSwitch removes a "--name" flag without value from the arguments and reports whether it was there
+func extractSwitch(args []string, name string) (bool, []string) {
+	for i, arg := range args {
+		if arg == name {
+			return true, append(append([]string{}, args[:i]...), args[i+1:]...)
+		}
+	}
+	return false, args
+}
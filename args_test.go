@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+		wantErr  bool
+	}{
+		{"words", "a b  c", []string{"a", "b", "c"}, false},
+		{"tabs", "a\tb", []string{"a", "b"}, false},
+		{"blank", "   ", nil, false},
+		{"double quotes", `create-folder alice "my docs"`, []string{"create-folder", "alice", "my docs"}, false},
+		{"single quotes keep double quotes", `'a "b"'`, []string{`a "b"`}, false},
+		{"double quotes keep single quotes", `"a 'b'"`, []string{"a 'b'"}, false},
+		{"quotes inside a word", `my" "docs`, []string{"my docs"}, false},
+		{"empty quotes", `a ""`, []string{"a", ""}, false},
+		{"escaped space", `a\ b`, []string{"a b"}, false},
+		{"escaped quote", `"a\"b"`, []string{`a"b`}, false},
+		{"no escape in single quotes", `'a\b'`, []string{`a\b`}, false},
+		{"unterminated double quote", `a "b c`, nil, true},
+		{"unterminated single quote", `'a`, nil, true},
+		{"trailing escape", `a\`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := splitArgs(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v but got '%v'", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("Expected %q but got %q", tt.expected, args)
+			}
+		})
+	}
+}
+
+func TestExtractFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		value    string
+		expected []string
+		wantErr  bool
+	}{
+		{"in the middle", []string{"alice", "--to", "dir", "docs"}, "dir", []string{"alice", "docs"}, false},
+		{"at the start", []string{"--to", "dir", "alice"}, "dir", []string{"alice"}, false},
+		{"absent", []string{"alice", "docs"}, "", []string{"alice", "docs"}, false},
+		{"first one only", []string{"--to", "a", "--to", "b"}, "a", []string{"--to", "b"}, false},
+		{"value that looks like a flag", []string{"--to", "--clear"}, "--clear", []string{}, false},
+		{"missing value", []string{"alice", "--to"}, "", []string{"alice", "--to"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]string{}, tt.args...)
+			value, rest, err := extractFlag(tt.args, "--to")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v but got '%v'", tt.wantErr, err)
+			}
+			if value != tt.value {
+				t.Errorf("Expected the value '%s' but got '%s'", tt.value, value)
+			}
+			if !reflect.DeepEqual(rest, tt.expected) {
+				t.Errorf("Expected %q but got %q", tt.expected, rest)
+			}
+			if !reflect.DeepEqual(tt.args, original) {
+				t.Errorf("Expected the arguments to stay %q but got %q", original, tt.args)
+			}
+		})
+	}
+}
+
+func TestExtractSwitch(t *testing.T) {
+	found, rest := extractSwitch([]string{"alice", "--literal", "docs"}, "--literal")
+	if !found || !reflect.DeepEqual(rest, []string{"alice", "docs"}) {
+		t.Errorf("Expected the switch to be removed but got %v %q", found, rest)
+	}
+	found, rest = extractSwitch([]string{"alice", "docs"}, "--literal")
+	if found || !reflect.DeepEqual(rest, []string{"alice", "docs"}) {
+		t.Errorf("Expected no switch but got %v %q", found, rest)
+	}
+}
+
+func TestRawArgsAfter(t *testing.T) {
+	tests := []struct {
+		line     string
+		n        int
+		expected string
+	}{
+		{"write-file alice docs a.txt  two   spaces", 4, "two   spaces"},
+		{`write-file alice docs a.txt "quoted text" more`, 4, `"quoted text" more`},
+		{"  write-file\talice", 1, "alice"},
+		{"write-file", 0, "write-file"},
+		{"write-file alice", 2, ""},
+		{"write-file alice ", 2, ""},
+	}
+	for _, tt := range tests {
+		if result := rawArgsAfter(tt.line, tt.n); result != tt.expected {
+			t.Errorf("Expected '%s' after %d words of '%s' but got '%s'", tt.expected, tt.n, tt.line, result)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command describes a REPL command for help, usage messages and completion
+type command struct {
+	name     string
+	synopsis string
+	summary  string
+	args     []argHelp
+	examples []string
+	// completes lists the kinds of the leading positional arguments
+	completes []argKind
+}
+
+// argHelp describes an argument or a flag of a command
+type argHelp struct {
+	name        string
+	description string
+}
+
+var (
+	userArg   = argHelp{"username", "The user, whatever the case of its name."}
+	folderArg = argHelp{"foldername", "A folder of the user."}
+	fileArg   = argHelp{"filename", "A file of the folder. Without it the folder itself is targeted."}
+	sortArgs  = []argHelp{
+		{"--sort-name|--sort-created|--sort-modified", "The sort field, name by default."},
+		{"asc|desc", "The sort order, ascending by default."},
+		{"--tag tag", "Only lists the entries carrying the tag."},
+		{"--limit n", "Lists at most n entries and prints the cursor of the next page."},
+		{"--after cursor", "Continues a listing after the cursor printed by the previous page."},
+	}
+)
+
+// commands lists every command in the order help shows them
+var commands = []command{
+	{
+		name:     "register",
+		synopsis: "register [username]",
+		summary:  "Registers a new user.",
+		args:     []argHelp{{"username", "Up to 50 characters, unique whatever the case."}},
+		examples: []string{"register alice"},
+	},
+	{
+		name:      "create-folder",
+		synopsis:  "create-folder [username] [foldername] [description]?",
+		summary:   "Creates a folder for a user.",
+		args:      []argHelp{userArg, {"foldername", "Up to 100 characters, unique for the user."}, {"description", "Optional, the remaining words are joined."}},
+		examples:  []string{"create-folder alice docs", `create-folder alice photos "summer 2024"`},
+		completes: []argKind{argUser},
+	},
+	{
+		name:      "delete-folder",
+		synopsis:  "delete-folder [username] [foldername]",
+		summary:   "Deletes a folder and its files.",
+		args:      []argHelp{userArg, folderArg},
+		examples:  []string{"delete-folder alice docs"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:      "list-folders",
+		synopsis:  "list-folders [username] [--sort-name|--sort-created|--sort-modified] [asc|desc] [--tag tag]? [--limit n]? [--after cursor]?",
+		summary:   "Lists the folders of a user.",
+		args:      append([]argHelp{userArg}, sortArgs...),
+		examples:  []string{"list-folders alice", "list-folders alice --sort-created desc --limit 10"},
+		completes: []argKind{argUser},
+	},
+	{
+		name:      "rename-folder",
+		synopsis:  "rename-folder [username] [foldername] [new-folder-name]",
+		summary:   "Renames a folder.",
+		args:      []argHelp{userArg, folderArg, {"new-folder-name", "Up to 100 characters, unique for the user."}},
+		examples:  []string{"rename-folder alice docs documents"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:      "create-file",
+		synopsis:  "create-file [username] [foldername] [filename] [description]?",
+		summary:   "Creates an empty file in a folder.",
+		args:      []argHelp{userArg, folderArg, {"filename", "Up to 255 characters, unique in the folder."}, {"description", "Optional, the remaining words are joined."}},
+		examples:  []string{"create-file alice docs notes.txt", `create-file alice docs todo.txt "things to do"`},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:     "delete-file",
+		synopsis: "delete-file [username] [foldername] [filename] [--literal]?",
+		summary:  "Deletes a file, or every file matching glob patterns after confirmation.",
+		args: []argHelp{userArg, {"foldername", "A folder of the user, or a pattern with *, ? or [...]."},
+			{"filename", "A file of the folder, or a pattern with *, ? or [...]."}, {"--literal", "Takes the names literally, for names containing [."}},
+		examples:  []string{"delete-file alice docs notes.txt", "delete-file alice '*' '*.tmp'"},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:     "list-files",
+		synopsis: "list-files [username] [foldername] [--literal]? [--sort-name|--sort-created|--sort-modified] [asc|desc] [--tag tag]? [--limit n]? [--after cursor]?",
+		summary:  "Lists the files of a folder, or of every folder matching a glob pattern.",
+		args: append([]argHelp{userArg, {"foldername", "A folder of the user, or a pattern with *, ? or [...]."},
+			{"--literal", "Takes the folder name literally."}}, sortArgs...),
+		examples:  []string{"list-files alice docs", "list-files alice docs --sort-modified desc", "list-files alice 'p*' --tag work"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:     "find",
+		synopsis: "find [username|--all] [--name glob] [--desc regex] [--created-after time] [--created-before time] [--type file|folder] [--sort-name|--sort-created|--sort-modified] [asc|desc]",
+		summary:  "Searches every folder of a user, or of every user, for folders and files.",
+		args: []argHelp{{"username|--all", "The user, or every user with --all."}, {"--name glob", "Matches the name against a glob pattern."},
+			{"--desc regex", "Matches the description against a regular expression."}, {"--created-after time", "Matches entries created after the time."},
+			{"--created-before time", "Matches entries created before the time."}, {"--type file|folder", "Only matches files or folders."},
+			sortArgs[0], sortArgs[1]},
+		examples:  []string{"find alice --name '*.txt'", "find --all --type folder --created-after 2024-01-01"},
+		completes: []argKind{argUser},
+	},
+	{
+		name:      "search",
+		synopsis:  "search [username|--all] [query]",
+		summary:   "Searches the names, descriptions and contents of folders and files.",
+		args:      []argHelp{{"username|--all", "The user, or every user with --all."}, {"query", `Every word must match, "quoted words" must match as a phrase.`}},
+		examples:  []string{"search alice budget", `search --all "annual report"`},
+		completes: []argKind{argUser},
+	},
+	{
+		name:     "set-description",
+		synopsis: "set-description [username] [foldername] [--file filename]? [description|--clear]",
+		summary:  "Replaces the description of a folder or a file.",
+		args: []argHelp{userArg, folderArg, {"--file filename", "A file of the folder. Without it the folder itself is targeted."},
+			{"description|--clear", "The new description, quoted when it has several words. --clear removes it."}},
+		examples:  []string{`set-description alice docs "work documents"`, "set-description alice docs --file notes.txt --clear"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:      "tag",
+		synopsis:  "tag [username] [foldername] [filename]? [tag]",
+		summary:   "Attaches a tag to a folder or a file.",
+		args:      []argHelp{userArg, folderArg, fileArg, {"tag", "The tag to attach."}},
+		examples:  []string{"tag alice docs work", "tag alice docs notes.txt urgent"},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:      "untag",
+		synopsis:  "untag [username] [foldername] [filename]? [tag]",
+		summary:   "Detaches a tag from a folder or a file.",
+		args:      []argHelp{userArg, folderArg, fileArg, {"tag", "The tag to detach."}},
+		examples:  []string{"untag alice docs work"},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:      "set-attr",
+		synopsis:  "set-attr [username] [foldername] [filename]? [key] [value]",
+		summary:   "Sets a key/value attribute on a folder or a file.",
+		args:      []argHelp{userArg, folderArg, fileArg, {"key", "The attribute name."}, {"value", "The attribute value, an empty value removes the attribute."}},
+		examples:  []string{"set-attr alice docs owner alice", `set-attr alice docs owner ""`},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:      "get-attr",
+		synopsis:  "get-attr [username] [foldername] [filename]? [key]",
+		summary:   "Prints an attribute of a folder or a file.",
+		args:      []argHelp{userArg, folderArg, fileArg, {"key", "The attribute name."}},
+		examples:  []string{"get-attr alice docs owner"},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:      "show-meta",
+		synopsis:  "show-meta [username] [foldername] [filename]? [--json]?",
+		summary:   "Prints the tags and attributes of a folder or a file.",
+		args:      []argHelp{userArg, folderArg, fileArg, {"--json", "Print them as a JSON object."}},
+		examples:  []string{"show-meta alice docs", "show-meta alice docs notes.txt --json"},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:      "write-file",
+		synopsis:  "write-file [username] [foldername] [filename] [content]",
+		summary:   "Replaces the content of a file.",
+		args:      []argHelp{userArg, folderArg, {"filename", "A file of the folder."}, {"content", "The new content, the remaining words are joined."}},
+		examples:  []string{`write-file alice docs notes.txt "buy milk"`},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:      "read-file",
+		synopsis:  "read-file [username] [foldername] [filename]",
+		summary:   "Prints the content of a file.",
+		args:      []argHelp{userArg, folderArg, {"filename", "A file of the folder."}},
+		examples:  []string{"read-file alice docs notes.txt"},
+		completes: []argKind{argUser, argFolder, argFile},
+	},
+	{
+		name:     "export",
+		synopsis: "export [username] [foldername]? --to [path]",
+		summary:  "Writes the folders of a user, or one folder, to a host directory or archive.",
+		args: []argHelp{
+			userArg,
+			{"foldername", "Only exports this folder, every folder of the user without it."},
+			{"--to path", "A .tar.gz, .tgz or .zip archive that must not exist, or else a directory that must be empty or missing."},
+		},
+		examples:  []string{"export alice --to backup.tar.gz", "export alice docs --to ./docs-export"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:     "import",
+		synopsis: "import [username] [path] [--on-invalid skip|rename]?",
+		summary:  "Creates folders and files from a host directory or archive.",
+		args: []argHelp{
+			userArg,
+			{"path", "A .tar.gz, .tgz or .zip archive, or else a directory. Its directories become folders and the files inside them files."},
+			{"--on-invalid skip|rename", "What to do with names that are invalid or taken, skip by default."},
+		},
+		examples:  []string{"import alice backup.tar.gz", "import alice ./photos --on-invalid rename"},
+		completes: []argKind{argUser},
+	},
+	{
+		name:     "sync",
+		synopsis: "sync [username] [foldername] [dir] [--pull|--push]? [--dry-run]?",
+		summary:  "Copies the changes between a folder and a host directory so that both match.",
+		args: []argHelp{
+			userArg,
+			folderArg,
+			{"dir", "The host directory, created when missing. Its subdirectories are not synced."},
+			{"--pull|--push", "Only copies the changes of the host directory, or of the folder. Both ways without them."},
+			{"--dry-run", "Lists the changes without making them."},
+		},
+		examples:  []string{"sync alice docs ./docs", "sync alice docs ./docs --push --dry-run"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:     "quota",
+		synopsis: "quota [username|--default] [set [folders|files|bytes] [limit]]?",
+		summary:  "Shows or changes the limits of a user.",
+		args: []argHelp{{"username|--default", "The user, or with --default the users without their own limits."},
+			{"set [folders|files|bytes] [limit]", "Changes a limit, 0 means unlimited."}},
+		examples:  []string{"quota alice", "quota alice set files 100", "quota --default set bytes 1048576"},
+		completes: []argKind{argUser},
+	},
+	{
+		name:      "watch",
+		synopsis:  "watch [username] [foldername]?",
+		summary:   "Prints the changes to the folders and files of a user as they happen.",
+		args:      []argHelp{userArg, {"foldername", "Only watches this folder, every folder of the user without it."}},
+		examples:  []string{"watch alice", "watch alice docs"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:      "unwatch",
+		synopsis:  "unwatch [username] [foldername]?",
+		summary:   "Stops a watch started with watch.",
+		args:      []argHelp{userArg, {"foldername", "The folder given to watch, if any."}},
+		examples:  []string{"unwatch alice docs"},
+		completes: []argKind{argUser, argFolder},
+	},
+	{
+		name:     "audit",
+		synopsis: "audit [--user username] [--action action] [--since time] [--until time]",
+		summary:  "Shows the audit log entries matching the filters, it needs -audit [path].",
+		args: []argHelp{{"--user username", "Only the calls made by the user."}, {"--action action", "Only the calls of the action, such as delete-folder."},
+			{"--since time", "Only the calls made at or after the time, given as YYYY-MM-DD, YYYY-MM-DDThh:mm:ss or RFC 3339."},
+			{"--until time", "Only the calls made before the time."}},
+		examples: []string{"audit --user alice", "audit --action delete-folder --since 2024-01-01"},
+	},
+	{
+		name:     "undo",
+		synopsis: "undo",
+		summary:  "Reverts the latest change made in the session.",
+		examples: []string{"undo"},
+	},
+	{
+		name:     "redo",
+		synopsis: "redo",
+		summary:  "Replays the latest undone change.",
+		examples: []string{"redo"},
+	},
+	{
+		name:     "stats",
+		synopsis: "stats",
+		summary:  "Shows the stored users, folders, files and bytes, and the calls with their latency, in Prometheus text format.",
+		examples: []string{"stats"},
+	},
+	{
+		name:     "case-mode",
+		synopsis: "case-mode [sensitive|insensitive|preserving]? [--check]",
+		summary:  "Shows or switches the case mode of folder and file names.",
+		args: []argHelp{{"sensitive|insensitive|preserving", "The mode to switch to, the current mode is shown without it."},
+			{"--check", "Only lists the names that would collide."}},
+		examples: []string{"case-mode", "case-mode insensitive --check"},
+	},
+	{
+		name:     "begin",
+		synopsis: "begin",
+		summary:  "Starts a transaction, applied completely or not at all.",
+		examples: []string{"begin"},
+	},
+	{
+		name:     "commit",
+		synopsis: "commit",
+		summary:  "Applies the changes of the transaction.",
+		examples: []string{"commit"},
+	},
+	{
+		name:     "rollback",
+		synopsis: "rollback",
+		summary:  "Discards the changes of the transaction.",
+		examples: []string{"rollback"},
+	},
+	{
+		name:     "help",
+		synopsis: "help [command]?",
+		summary:  "Lists the commands, or describes one. [command] --help does the same.",
+		args:     []argHelp{{"command", "The command to describe."}},
+		examples: []string{"help", "help list-files", "list-files --help"},
+	},
+	{
+		name:     "exit",
+		synopsis: "exit",
+		summary:  "Leaves the program, saving the command history.",
+		examples: []string{"exit"},
+	},
+}
+
+// exitCodes describes the exit status of the program
+const exitCodes = `Exit codes:
+  0  exit was typed or the input ended
+  1  the audit log could not be opened or a flag value is invalid
+  2  the command line flags could not be parsed`
+
+// lookupCommand returns the command with the name, or nil
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// usage returns the usage message of the command
+func usage(name string) error {
+	return fmt.Errorf("Usage: %s", lookupCommand(name).synopsis)
+}
+
+// printUsage reports a command called with the wrong arguments
+func printUsage(name string) {
+	fmt.Fprintln(os.Stderr, usage(name))
+	fmt.Fprintf(os.Stderr, "Run 'help %s' for details.\n", name)
+}
+
+// printHelp lists the commands, or describes the named one
+func printHelp(w io.Writer, name string) error {
+	if name == "" {
+		fmt.Fprintln(w, "Commands:")
+		for _, c := range commands {
+			fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintln(w, "\nRun 'help [command]' for the arguments and examples of a command.")
+		fmt.Fprintln(w, "\n"+exitCodes)
+		return nil
+	}
+
+	c := lookupCommand(name)
+	if c == nil {
+		return unknownCommand(name)
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", c.synopsis, c.summary)
+	if len(c.args) > 0 {
+		fmt.Fprintln(w, "\nArguments:")
+		for _, arg := range c.args {
+			fmt.Fprintf(w, "  %s\n      %s\n", arg.name, arg.description)
+		}
+	}
+	if len(c.examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		for _, example := range c.examples {
+			fmt.Fprintf(w, "  # %s\n", example)
+		}
+	}
+	if c.name == "exit" {
+		fmt.Fprintln(w, "\n"+exitCodes)
+	}
+	return nil
+}
+
+// unknownCommand returns the error of an unknown command, suggesting the closest one
+func unknownCommand(name string) error {
+	if suggestion := closestCommand(name); suggestion != "" {
+		return fmt.Errorf("Error: Unrecognized command. Did you mean %s?", suggestion)
+	}
+	return fmt.Errorf("Error: Unrecognized command.")
+}
+
+// closestCommand returns the command closest to the name, or an empty string when
+// none is close enough to be a typo
+func closestCommand(name string) string {
+	best, bestDistance := "", len(name)/3+2
+	for _, c := range commands {
+		distance := editDistance(name, c.name)
+		if strings.HasPrefix(c.name, name) && len(name) >= 3 {
+			distance = 1
+		}
+		if distance < bestDistance {
+			best, bestDistance = c.name, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestClosestCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"regster", "register"},
+		{"lst-folders", "list-folders"},
+		{"comit", "commit"},
+		{"undoo", "undo"},
+		// A prefix of at least 3 characters suggests the first command it starts
+		{"del", "delete-folder"},
+		{"rena", "rename-folder"},
+		// Shorter or farther names suggest nothing
+		{"de", ""},
+		{"xyz", ""},
+		{"", ""},
+		{"completely-unknown", ""},
+	}
+	for _, tt := range tests {
+		if result := closestCommand(tt.name); result != tt.expected {
+			t.Errorf("Expected '%s' for '%s' but got '%s'", tt.expected, tt.name, result)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"same", "same", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		// Characters are counted as runes, not bytes
+		{"名字", "名子", 1},
+	}
+	for _, tt := range tests {
+		if result := editDistance(tt.a, tt.b); result != tt.expected {
+			t.Errorf("Expected %d between '%s' and '%s' but got %d", tt.expected, tt.a, tt.b, result)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"iscool/vfs/controller"
+	"sort"
+	"strings"
+)
+
+// argKind is what a positional argument of a command names
+type argKind int
+
+const (
+	argUser argKind = iota + 1
+	argFolder
+	argFile
+)
+
+// complete returns the completions of the word under the cursor, given the line before
+// and after it. Commands complete first, then the usernames, folders and files that exist
+// for the arguments typed so far.
+func complete(fs *controller.FileSystem, line string, pos int) (string, []string, string) {
+	head, tail := line[:pos], line[pos:]
+	args, err := splitArgs(head)
+	if err != nil {
+		// Inside an unterminated quote
+		return head, nil, tail
+	}
+
+	word := ""
+	if len(args) > 0 && !strings.HasSuffix(head, " ") && !strings.HasSuffix(head, "\t") {
+		word = args[len(args)-1]
+		if !strings.HasSuffix(head, word) {
+			// The word is quoted or escaped
+			return head, nil, tail
+		}
+		args = args[:len(args)-1]
+		head = head[:len(head)-len(word)]
+	}
+
+	var candidates []string
+	if len(args) == 0 {
+		for _, c := range commands {
+			candidates = append(candidates, c.name)
+		}
+	} else {
+		var positional []string
+		for _, arg := range args[1:] {
+			if !strings.HasPrefix(arg, "--") {
+				positional = append(positional, arg)
+			}
+		}
+		if c := lookupCommand(args[0]); c != nil && len(positional) < len(c.completes) {
+			candidates = names(fs, c.completes[len(positional)], positional)
+		}
+	}
+
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			completions = append(completions, candidate+" ")
+		}
+	}
+	sort.Strings(completions)
+	return head, completions, tail
+}
+
+// names returns the existing names of the kind, within the user and folder given in args
+func names(fs *controller.FileSystem, kind argKind, args []string) []string {
+	switch kind {
+	case argUser:
+		return fs.Usernames()
+	case argFolder:
+		return fs.FolderNames(args[0])
+	case argFile:
+		return fs.FileNames(args[0], args[1])
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"iscool/vfs/controller"
+	"reflect"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	fs := controller.NewFileSystem()
+	if err := fs.Register("alice"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.Register("bob"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, name := range []string{"docs", "downloads", "music"} {
+		if err := fs.CreateFolder("alice", name, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+	for _, name := range []string{"notes.txt", "[draft].txt"} {
+		if err := fs.CreateFile("alice", "docs", name, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+
+	observed := 0
+	defer fs.OnBefore("", 0, func(call controller.Call) error {
+		observed++
+		return nil
+	})()
+
+	tests := []struct {
+		name     string
+		line     string
+		head     string
+		expected []string
+	}{
+		{"command", "cre", "", []string{"create-file ", "create-folder "}},
+		{"user", "list-folders a", "list-folders ", []string{"alice "}},
+		{"every user", "list-folders ", "list-folders ", []string{"alice ", "bob "}},
+		{"folder", "delete-folder alice d", "delete-folder alice ", []string{"docs ", "downloads "}},
+		{"file", "delete-file alice docs ", "delete-file alice docs ", []string{"[draft].txt ", "notes.txt "}},
+		{"flags are skipped", "delete-file --literal alice docs n", "delete-file --literal alice docs ", []string{"notes.txt "}},
+		{"past the last argument", "delete-file alice docs notes.txt ", "delete-file alice docs notes.txt ", nil},
+		{"unknown user", "list-folders nobody ", "list-folders nobody ", nil},
+		{"unknown command", "nothing a", "nothing ", nil},
+		{"quoted word", `delete-folder alice "d`, `delete-folder alice "d`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, completions, tail := complete(fs, tt.line, len(tt.line))
+			if head != tt.head {
+				t.Errorf("Expected the head '%s' but got '%s'", tt.head, head)
+			}
+			if !reflect.DeepEqual(completions, tt.expected) {
+				t.Errorf("Expected %q but got %q", tt.expected, completions)
+			}
+			if tail != "" {
+				t.Errorf("Expected no tail but got '%s'", tail)
+			}
+		})
+	}
+
+	// The words after the cursor are kept
+	head, completions, tail := complete(fs, "delete-folder alice mu extra", len("delete-folder alice mu"))
+	if head != "delete-folder alice " || !reflect.DeepEqual(completions, []string{"music "}) || tail != " extra" {
+		t.Errorf("Expected music to complete before ' extra' but got '%s' %q '%s'", head, completions, tail)
+	}
+
+	if observed != 0 {
+		t.Errorf("Expected completion to run no hooks but %d calls were observed", observed)
+	}
+}
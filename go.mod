@@ -1,3 +1,13 @@
 module iscool
 
-go 1.20
+go 1.21
+
+require (
+	github.com/peterh/liner v1.2.2
+	golang.org/x/text v0.14.0
+)
+
+require (
+	github.com/mattn/go-runewidth v0.0.3 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+)
@@ -1,25 +1,147 @@
 package main
 
 import (
-	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"iscool/vfs/audit"
 	"iscool/vfs/controller"
+	"iscool/vfs/metrics"
+	"log/slog"
+	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/peterh/liner"
 )
 
+// historyFile is the name of the command history kept in the home directory
+const historyFile = ".iscool_history"
+
 func main() {
-	fs := controller.NewFileSystem()
+	auditPath := flag.String("audit", "", "path of the append-only audit log, none by default")
+	caseFlag := flag.String("case", "sensitive", "case mode of folder and file names: sensitive, insensitive or preserving")
+	tzFlag := flag.String("tz", "Local", "time zone of the listed times, as an IANA name such as UTC or Asia/Taipei")
+	timeFormat := flag.String("time-format", controller.DefaultTimeFormat, "Go layout of the listed times")
+	metricsAddr := flag.String("metrics", "", "address serving the metrics on /metrics, such as :9090")
+	logLevel := flag.String("log-level", "", "level of the logs written to stderr: debug, info, warn or error, none by default")
+	logFormat := flag.String("log-format", "text", "format of the logs: text or json")
+	flag.Parse()
+
+	logger, err := newLogger(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	location, err := time.LoadLocation(*tzFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Unknown time zone %s.\n", *tzFlag)
+		os.Exit(1)
+	}
+
+	caseMode, err := controller.ParseCaseMode(*caseFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var auditLog *audit.Log
+	if *auditPath != "" {
+		auditFile, err := os.OpenFile(*auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer auditFile.Close()
+		auditLog = audit.NewLog(auditFile)
+	}
+
+	registry := metrics.NewRegistry()
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", registry)
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	opts := []controller.Option{
+		controller.WithHistory(controller.NewHistory()),
+		controller.WithCaseMode(caseMode),
+		controller.WithTimeFormat(*timeFormat, location),
+		controller.WithMetrics(registry),
+	}
+	if auditLog != nil {
+		opts = append(opts, controller.WithAuditLog(auditLog))
+	}
+	if logger != nil {
+		opts = append(opts, controller.WithLogger(logger))
+	}
+	base := controller.NewFileSystem(opts...)
+	// fs is the transaction in progress, if any, or the file system itself
+	fs := base
+	var tx *controller.Tx
+	watchers := make(map[string]*controller.Watcher)
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(func(text string, pos int) (string, []string, string) {
+		return complete(fs, text, pos)
+	})
+
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, historyFile)
+		if f, err := os.Open(historyPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+	defer func() {
+		if historyPath == "" {
+			return
+		}
+		if f, err := os.OpenFile(historyPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); err == nil {
+			line.WriteHistory(f)
+			f.Close()
+		}
+	}()
 
-	scanner := bufio.NewScanner(os.Stdin)
 	for {
-		fmt.Print("# ")
-		if !scanner.Scan() {
+		if tx != nil && tx.Done() {
+			fmt.Fprintln(os.Stderr, "Warning: The transaction was rolled back.")
+			fs, tx = base, nil
+		}
+
+		input, err := line.Prompt("# ")
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintln(os.Stderr, err)
+			}
 			break
 		}
+		if strings.TrimSpace(input) != "" {
+			line.AppendHistory(input)
+		}
 
-		line := scanner.Text()
-		args := strings.Fields(line)
+		args, err := splitArgs(input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
 		if len(args) == 0 {
 			continue
 		}
@@ -27,10 +149,29 @@ func main() {
 		command := args[0]
 		commandArgs := args[1:]
 
+		if command == "help" {
+			if len(commandArgs) > 1 {
+				printUsage(command)
+				continue
+			}
+			name := ""
+			if len(commandArgs) == 1 {
+				name = commandArgs[0]
+			}
+			if err := printHelp(os.Stdout, name); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			continue
+		}
+		if help, _ := extractSwitch(commandArgs, "--help"); help && lookupCommand(command) != nil {
+			printHelp(os.Stdout, command)
+			continue
+		}
+
 		switch command {
 		case "register":
 			if len(commandArgs) < 1 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
@@ -45,7 +186,7 @@ func main() {
 
 		case "create-folder":
 			if len(commandArgs) < 2 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
@@ -53,7 +194,7 @@ func main() {
 			foldername := commandArgs[1]
 			description := ""
 			if len(commandArgs) > 2 {
-				description = strings.Join(commandArgs[2:], "")
+				description = strings.Join(commandArgs[2:], " ")
 			}
 
 			err := fs.CreateFolder(username, foldername, description)
@@ -65,7 +206,7 @@ func main() {
 
 		case "delete-folder":
 			if len(commandArgs) < 2 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
@@ -79,8 +220,13 @@ func main() {
 			}
 
 		case "list-folders":
+			opts, commandArgs, err := parseListOptions(commandArgs)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
 			if len(commandArgs) < 1 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
@@ -96,16 +242,19 @@ func main() {
 				sortOrder = commandArgs[2]
 			}
 
-			output, err := fs.ListFolders(username, sortBy, sortOrder)
+			output, next, err := fs.ListFoldersPage(username, sortBy, sortOrder, opts...)
 			if err != nil {
 				fmt.Println(err)
 			} else {
 				fmt.Println(output)
+				if next != "" {
+					fmt.Printf("Next page: --after %s\n", next)
+				}
 			}
 
 		case "rename-folder":
 			if len(commandArgs) < 3 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
@@ -121,7 +270,7 @@ func main() {
 
 		case "create-file":
 			if len(commandArgs) < 3 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
@@ -130,7 +279,7 @@ func main() {
 			filename := commandArgs[2]
 			description := ""
 			if len(commandArgs) > 3 {
-				description = strings.Join(commandArgs[3:], "")
+				description = strings.Join(commandArgs[3:], " ")
 			}
 			err := fs.CreateFile(username, foldername, filename, description)
 			if err != nil {
@@ -140,14 +289,72 @@ func main() {
 			}
 
 		case "delete-file":
+			literal, commandArgs := extractSwitch(commandArgs, "--literal")
 			if len(commandArgs) < 3 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
 			username := commandArgs[0]
 			foldername := commandArgs[1]
 			filename := commandArgs[2]
+
+			var matches []controller.FileMatch
+			if !literal && (controller.HasGlob(foldername) || controller.HasGlob(filename)) {
+				var err error
+				if matches, err = fs.GlobFiles(username, foldername, filename); err != nil {
+					fmt.Println(err)
+					continue
+				}
+			}
+			// A name containing '[' that names the file is not a bulk delete
+			if len(matches) == 1 && matches[0] == (controller.FileMatch{Folder: foldername, File: filename}) {
+				matches = nil
+				literal = true
+			}
+			if !literal && (controller.HasGlob(foldername) || controller.HasGlob(filename)) {
+				if len(matches) == 0 {
+					fmt.Printf("Warning: No files match %s/%s.\n", foldername, filename)
+					continue
+				}
+
+				for _, match := range matches {
+					fmt.Printf("%s/%s/%s\n", username, match.Folder, match.File)
+				}
+				if !confirm(line, fmt.Sprintf("Delete these %d files? [y/N] ", len(matches))) {
+					fmt.Println("Delete cancelled.")
+					continue
+				}
+				// The files are deleted in one transaction, so the delete applies and is undone as a whole
+				var err error
+				run, bulk := fs, tx
+				if tx == nil {
+					if bulk, err = base.Begin(); err != nil {
+						fmt.Println(err)
+						continue
+					}
+					run = bulk.FileSystem
+				}
+				for _, match := range matches {
+					if err = run.DeleteFile(username, match.Folder, match.File); err != nil {
+						break
+					}
+				}
+				if err == nil && tx == nil {
+					if err = bulk.Commit(); err != nil && !bulk.Done() {
+						bulk.Rollback()
+					}
+				}
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				for _, match := range matches {
+					fmt.Printf("Delete %s in %s/%s successfully.\n", match.File, username, match.Folder)
+				}
+				continue
+			}
+
 			err := fs.DeleteFile(username, foldername, filename)
 			if err != nil {
 				fmt.Println(err)
@@ -155,9 +362,183 @@ func main() {
 				fmt.Printf("Delete %s in %s/%s successfully.\n", filename, username, foldername)
 			}
 
+		case "set-description":
+			filename, rest, err := extractFlag(commandArgs, "--file")
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if len(rest) != 3 {
+				printUsage(command)
+				continue
+			}
+
+			username := rest[0]
+			foldername := rest[1]
+			description := rest[2]
+			if description == "--clear" {
+				description = ""
+			}
+
+			if filename == "" {
+				err := fs.SetFolderDescription(username, foldername, description)
+				if err != nil {
+					fmt.Println(err)
+				} else {
+					fmt.Printf("Set the description of %s successfully.\n", foldername)
+				}
+				continue
+			}
+
+			err = fs.SetFileDescription(username, foldername, filename, description)
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Printf("Set the description of %s in %s/%s successfully.\n", filename, username, foldername)
+			}
+
+		case "tag", "untag":
+			if len(commandArgs) < 3 || len(commandArgs) > 4 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			foldername := commandArgs[1]
+			filename := ""
+			if len(commandArgs) == 4 {
+				filename = commandArgs[2]
+			}
+			tag := commandArgs[len(commandArgs)-1]
+
+			var err error
+			if command == "tag" {
+				err = fs.AddTag(username, foldername, filename, tag)
+			} else {
+				err = fs.RemoveTag(username, foldername, filename, tag)
+			}
+			if err != nil {
+				fmt.Println(err)
+			} else if command == "tag" {
+				fmt.Printf("Tag %s with %s successfully.\n", displayTarget(username, foldername, filename), tag)
+			} else {
+				fmt.Printf("Untag %s from %s successfully.\n", tag, displayTarget(username, foldername, filename))
+			}
+
+		case "set-attr":
+			if len(commandArgs) < 4 || len(commandArgs) > 5 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			foldername := commandArgs[1]
+			filename := ""
+			if len(commandArgs) == 5 {
+				filename = commandArgs[2]
+			}
+			key := commandArgs[len(commandArgs)-2]
+			value := commandArgs[len(commandArgs)-1]
+			err := fs.SetAttribute(username, foldername, filename, key, value)
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Printf("Set %s of %s successfully.\n", key, displayTarget(username, foldername, filename))
+			}
+
+		case "get-attr":
+			if len(commandArgs) < 3 || len(commandArgs) > 4 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			foldername := commandArgs[1]
+			filename := ""
+			if len(commandArgs) == 4 {
+				filename = commandArgs[2]
+			}
+			key := commandArgs[len(commandArgs)-1]
+			value, err := fs.GetAttribute(username, foldername, filename, key)
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println(value)
+			}
+
+		case "show-meta":
+			asJSON, commandArgs := extractSwitch(commandArgs, "--json")
+			if len(commandArgs) < 2 || len(commandArgs) > 3 {
+				printUsage(command)
+				continue
+			}
+
+			filename := ""
+			if len(commandArgs) == 3 {
+				filename = commandArgs[2]
+			}
+			metadata, err := fs.GetMetadata(commandArgs[0], commandArgs[1], filename)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if asJSON {
+				data, _ := json.Marshal(metadata)
+				fmt.Println(string(data))
+				continue
+			}
+			fmt.Println("tags: " + strings.Join(metadata.Tags, ", "))
+			keys := make([]string, 0, len(metadata.Attributes))
+			for key := range metadata.Attributes {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Println(key + "=" + metadata.Attributes[key])
+			}
+
+		case "write-file":
+			if len(commandArgs) < 3 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			foldername := commandArgs[1]
+			filename := commandArgs[2]
+			content := strings.Join(commandArgs[3:], " ")
+			err := fs.WriteFile(username, foldername, filename, []byte(content))
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Printf("Write %s in %s/%s successfully.\n", filename, username, foldername)
+			}
+
+		case "read-file":
+			if len(commandArgs) < 3 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			foldername := commandArgs[1]
+			filename := commandArgs[2]
+			content, err := fs.ReadFile(username, foldername, filename)
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println(string(content))
+			}
+
 		case "list-files":
+			literal, commandArgs := extractSwitch(commandArgs, "--literal")
+			opts, commandArgs, err := parseListOptions(commandArgs)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
 			if len(commandArgs) < 2 {
-				fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+				printUsage(command)
 				continue
 			}
 
@@ -173,17 +554,572 @@ func main() {
 				sortOrder = commandArgs[3]
 			}
 
-			output, err := fs.ListFiles(username, foldername, sortBy, sortOrder)
+			var folders []string
+			if !literal && controller.HasGlob(foldername) {
+				if folders, err = fs.GlobFolders(username, foldername); err != nil {
+					fmt.Println(err)
+					continue
+				}
+			}
+			// A name containing '[' that names the folder is listed like any other
+			if len(folders) == 1 && folders[0] == foldername {
+				literal = true
+			}
+			if !literal && controller.HasGlob(foldername) {
+				if len(folders) == 0 {
+					fmt.Printf("Warning: No folders match %s.\n", foldername)
+					continue
+				}
+				for _, folder := range folders {
+					output, err := fs.ListFiles(username, folder, sortBy, sortOrder, opts...)
+					fmt.Printf("%s/%s:\n", username, folder)
+					if err != nil {
+						fmt.Println(err)
+					} else {
+						fmt.Println(output)
+					}
+				}
+				continue
+			}
+
+			output, next, err := fs.ListFilesPage(username, foldername, sortBy, sortOrder, opts...)
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println(output)
+				if next != "" {
+					fmt.Printf("Next page: --after %s\n", next)
+				}
+			}
+
+		case "find":
+			query, commandArgs, err := parseFindQuery(commandArgs)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if len(commandArgs) < 1 || len(commandArgs) > 3 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			if username == "--all" {
+				username = ""
+			}
+			var sortBy, sortOrder string
+			switch len(commandArgs) {
+			case 2:
+				sortBy = commandArgs[1]
+			case 3:
+				sortBy = commandArgs[1]
+				sortOrder = commandArgs[2]
+			}
+
+			output, err := fs.Find(username, query, sortBy, sortOrder)
 			if err != nil {
 				fmt.Println(err)
 			} else {
 				fmt.Println(output)
 			}
 
+		case "search":
+			if len(commandArgs) < 2 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			if username == "--all" {
+				username = ""
+			}
+			// Keep the quotes of the query, they mark phrases
+			query := rawArgsAfter(input, 2)
+			output, err := fs.Search(username, query)
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println(output)
+			}
+
+		case "watch":
+			if len(commandArgs) < 1 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			foldername := ""
+			if len(commandArgs) > 1 {
+				foldername = commandArgs[1]
+			}
+			key := username
+			if foldername != "" {
+				key += "/" + foldername
+			}
+			if _, ok := watchers[key]; ok {
+				fmt.Printf("Already watching %s.\n", key)
+				continue
+			}
+
+			w, err := fs.Watch(username, foldername)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			watchers[key] = w
+			go func() {
+				for event := range w.Events {
+					fmt.Println(event.Format(*timeFormat, location))
+				}
+			}()
+			fmt.Printf("Watch %s successfully.\n", key)
+
+		case "unwatch":
+			if len(commandArgs) < 1 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			foldername := ""
+			if len(commandArgs) > 1 {
+				foldername = commandArgs[1]
+			}
+			key := username
+			if foldername != "" {
+				key += "/" + foldername
+			}
+			w, ok := watchers[key]
+			if !ok {
+				fmt.Printf("Error: %s is not watched.\n", key)
+				continue
+			}
+			w.Close()
+			delete(watchers, key)
+			fmt.Printf("Unwatch %s successfully.\n", key)
+
+		case "export":
+			dest, rest, err := extractFlag(commandArgs, "--to")
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if dest == "" || len(rest) < 1 || len(rest) > 2 {
+				printUsage(command)
+				continue
+			}
+
+			foldername := ""
+			if len(rest) > 1 {
+				foldername = rest[1]
+			}
+			manifest, err := fs.Export(rest[0], foldername, dest)
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Printf("Export %d folders and %d files to %s successfully.\n", len(manifest.Folders), manifest.Files(), dest)
+			}
+
+		case "import":
+			policyName, rest, err := extractFlag(commandArgs, "--on-invalid")
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if len(rest) != 2 {
+				printUsage(command)
+				continue
+			}
+
+			policy := controller.ImportSkip
+			if policyName != "" {
+				if policy, err = controller.ParseImportPolicy(policyName); err != nil {
+					fmt.Println(err)
+					continue
+				}
+			}
+			report, err := fs.Import(rest[0], rest[1], policy)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			for _, issue := range report.Issues {
+				fmt.Println(issue)
+			}
+			fmt.Printf("Import %d folders and %d files from %s successfully.\n", report.Folders, report.Files, rest[1])
+
+		case "sync":
+			pull, rest := extractSwitch(commandArgs, "--pull")
+			push, rest := extractSwitch(rest, "--push")
+			dryRun, rest := extractSwitch(rest, "--dry-run")
+			if len(rest) != 3 || (pull && push) {
+				printUsage(command)
+				continue
+			}
+
+			mode := controller.SyncBoth
+			if pull {
+				mode = controller.SyncPull
+			} else if push {
+				mode = controller.SyncPush
+			}
+			report, err := fs.Sync(rest[0], rest[1], rest[2], mode, dryRun)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			for _, change := range report.Changes {
+				fmt.Println(change)
+			}
+			if conflicts := report.Conflicts(); conflicts > 0 {
+				fmt.Printf("Warning: %d files changed on both sides were left alone.\n", conflicts)
+			}
+			if dryRun {
+				fmt.Println("Dry run, no changes were made.")
+			} else {
+				fmt.Printf("Sync %s/%s with %s successfully.\n", rest[0], rest[1], rest[2])
+			}
+
+		case "quota":
+			if len(commandArgs) != 1 && len(commandArgs) != 4 {
+				printUsage(command)
+				continue
+			}
+
+			username := commandArgs[0]
+			var usage controller.Usage
+			var quota controller.Quota
+			if username == "--default" {
+				quota = fs.DefaultQuota()
+			} else {
+				var err error
+				usage, quota, err = fs.GetQuota(username)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+			}
+
+			if len(commandArgs) == 1 {
+				fmt.Printf("folders %d/%s\n", usage.Folders, formatLimit(int64(quota.Folders)))
+				fmt.Printf("files %d/%s\n", usage.Files, formatLimit(int64(quota.Files)))
+				fmt.Printf("bytes %d/%s\n", usage.Bytes, formatLimit(quota.Bytes))
+				continue
+			}
+
+			limit, err := strconv.ParseInt(commandArgs[3], 10, 64)
+			if commandArgs[1] != "set" || err != nil {
+				printUsage(command)
+				continue
+			}
+			switch commandArgs[2] {
+			case "folders":
+				quota.Folders = int(limit)
+			case "files":
+				quota.Files = int(limit)
+			case "bytes":
+				quota.Bytes = limit
+			default:
+				printUsage(command)
+				continue
+			}
+
+			owner := username
+			if username == "--default" {
+				owner = "the default"
+				err = fs.SetDefaultQuota(quota)
+			} else {
+				err = fs.SetUserQuota(username, quota)
+			}
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Printf("Set the %s quota of %s to %s successfully.\n", commandArgs[2], owner, formatLimit(limit))
+			}
+
+		case "audit":
+			filter, err := parseAuditFilter(commandArgs)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if *auditPath == "" {
+				fmt.Fprintln(os.Stderr, "Error: The audit log is off, start with -audit [path] to write one.")
+				continue
+			}
+
+			f, err := os.Open(*auditPath)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			entries, err := audit.Read(f, filter)
+			f.Close()
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if len(entries) == 0 {
+				fmt.Println("Warning: No audit entries found.")
+				continue
+			}
+			for _, entry := range entries {
+				fmt.Println(entry.Format(*timeFormat, location))
+			}
+
+		case "undo", "redo":
+			if len(commandArgs) > 0 {
+				printUsage(command)
+				continue
+			}
+
+			undo, verb := fs.Undo, "Undo"
+			if command == "redo" {
+				undo, verb = fs.Redo, "Redo"
+			}
+			action, err := undo()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				fmt.Printf("%s %s successfully.\n", verb, action)
+			}
+
+		case "stats":
+			registry.WriteText(os.Stdout)
+
+		case "case-mode":
+			if len(commandArgs) == 0 {
+				fmt.Printf("Folder and file names are case-%s.\n", fs.CaseMode())
+				continue
+			}
+			check, rest := extractSwitch(commandArgs, "--check")
+			if len(rest) != 1 {
+				printUsage(command)
+				continue
+			}
+			mode, err := controller.ParseCaseMode(rest[0])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+
+			collisions, err := fs.CaseCollisions(mode)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			for _, collision := range collisions {
+				fmt.Println(collision)
+			}
+			if check {
+				if len(collisions) == 0 {
+					fmt.Printf("No names collide in %s mode.\n", mode)
+				}
+				continue
+			}
+			if err := fs.SetCaseMode(mode); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				fmt.Printf("Switch to %s mode successfully.\n", mode)
+			}
+
+		case "begin":
+			if len(commandArgs) > 0 {
+				printUsage(command)
+				continue
+			}
+			if tx != nil {
+				fmt.Fprintln(os.Stderr, "Error: A transaction is already in progress.")
+				continue
+			}
+
+			tx, err = base.Begin()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			fs = tx.FileSystem
+			fmt.Println("Begin a transaction successfully.")
+
+		case "commit", "rollback":
+			if len(commandArgs) > 0 {
+				printUsage(command)
+				continue
+			}
+			if tx == nil {
+				fmt.Fprintln(os.Stderr, "Error: No transaction in progress.")
+				continue
+			}
+
+			end, verb := tx.Commit, "Commit"
+			if command == "rollback" {
+				end, verb = tx.Rollback, "Roll back"
+			}
+			err := end()
+			// The transaction stays in progress when the call did not end it
+			if tx.Done() {
+				fs, tx = base, nil
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				fmt.Printf("%s the transaction successfully.\n", verb)
+			}
+
 		case "exit":
-			os.Exit(0)
+			return
 		default:
-			fmt.Fprintln(os.Stderr, "Error: Unrecognized command.")
+			fmt.Fprintln(os.Stderr, unknownCommand(command))
 		}
 	}
 }
+
+// parseAuditFilter parses the flags of the audit command
+func parseAuditFilter(args []string) (audit.Filter, error) {
+	var filter audit.Filter
+	errUsage := usage("audit")
+
+	if len(args)%2 != 0 {
+		return filter, errUsage
+	}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch args[i] {
+		case "--user":
+			filter.Actor = value
+		case "--action":
+			filter.Action = value
+		case "--since", "--until":
+			t, err := audit.ParseTime(value)
+			if err != nil {
+				return filter, err
+			}
+			if args[i] == "--since" {
+				filter.Since = t
+			} else {
+				filter.Until = t
+			}
+		default:
+			return filter, errUsage
+		}
+	}
+	return filter, nil
+}
+
+// formatLimit formats a quota limit, where zero means unlimited
+func formatLimit(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// displayTarget names a folder, or a file when filename is not empty, in command output
+func displayTarget(username, foldername, filename string) string {
+	if filename == "" {
+		return foldername
+	}
+	return fmt.Sprintf("%s in %s/%s", filename, username, foldername)
+}
+
+// parseFindQuery removes the predicate flags of the find command from the arguments
+func parseFindQuery(args []string) (controller.FindQuery, []string, error) {
+	var query controller.FindQuery
+	var err error
+
+	if query.Name, args, err = extractFlag(args, "--name"); err != nil {
+		return query, args, err
+	}
+	if query.Description, args, err = extractFlag(args, "--desc"); err != nil {
+		return query, args, err
+	}
+	if query.Type, args, err = extractFlag(args, "--type"); err != nil {
+		return query, args, err
+	}
+
+	for _, bound := range []struct {
+		flag string
+		time *time.Time
+	}{
+		{"--created-after", &query.CreatedAfter},
+		{"--created-before", &query.CreatedBefore},
+	} {
+		var value string
+		if value, args, err = extractFlag(args, bound.flag); err != nil {
+			return query, args, err
+		}
+		if value == "" {
+			continue
+		}
+		if *bound.time, err = audit.ParseTime(value); err != nil {
+			return query, args, err
+		}
+	}
+	return query, args, nil
+}
+
+// parseListOptions removes the filter and paging flags of the list commands from the arguments
+func parseListOptions(args []string) ([]controller.ListOption, []string, error) {
+	var opts []controller.ListOption
+
+	tag, args, err := extractFlag(args, "--tag")
+	if err != nil {
+		return nil, args, err
+	}
+	if tag != "" {
+		opts = append(opts, controller.WithTag(tag))
+	}
+
+	limit, args, err := extractFlag(args, "--limit")
+	if err != nil {
+		return nil, args, err
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, args, fmt.Errorf("Error: The --limit flag needs a positive number.")
+		}
+		opts = append(opts, controller.WithLimit(n))
+	}
+
+	after, args, err := extractFlag(args, "--after")
+	if err != nil {
+		return nil, args, err
+	}
+	if after != "" {
+		opts = append(opts, controller.WithAfter(after))
+	}
+	return opts, args, nil
+}
+
+// newLogger returns the logger writing to stderr, or nil when no level is given
+func newLogger(level, format string) (*slog.Logger, error) {
+	if level == "" {
+		return nil, nil
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("Error: Unknown log level %s. Valid levels are 'debug' 'info' 'warn' 'error'", level)
+	}
+	opts := slog.HandlerOptions{Level: lvl}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, &opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, &opts)), nil
+	}
+	return nil, fmt.Errorf("Error: Unknown log format %s. Valid formats are 'text' 'json'", format)
+}
+
+// confirm asks a yes or no question on the terminal, anything but y or yes is a no
+func confirm(line *liner.State, prompt string) bool {
+	input, err := line.Prompt(prompt)
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(input))
+	return answer == "y" || answer == "yes"
+}
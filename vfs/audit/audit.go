@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Results recorded in an Entry
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Entry is a single audited call
+type Entry struct {
+	Time   time.Time         `json:"time"`
+	Actor  string            `json:"actor"`
+	Action string            `json:"action"`
+	Target string            `json:"target"`
+	Args   map[string]string `json:"args,omitempty"`
+	Result string            `json:"result"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// String formats the entry for display
+func (e Entry) String() string {
+	return e.Format("2006-01-02 15:04:05", nil)
+}
+
+// Format formats the entry for display with a time layout and zone.
+// A nil location keeps the location of the entry time.
+func (e Entry) Format(layout string, location *time.Location) string {
+	t := e.Time
+	if location != nil {
+		t = t.In(location)
+	}
+	parts := []string{t.Format(layout), e.Actor, e.Action, e.Target}
+
+	keys := make([]string, 0, len(e.Args))
+	for key := range e.Args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%q", key, e.Args[key]))
+	}
+
+	parts = append(parts, e.Result)
+	if e.Error != "" {
+		parts = append(parts, e.Error)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Log appends entries to a writer as JSON lines
+type Log struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLog returns a log writing to w, which should be opened in append mode
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// Write appends the entry to the log
+func (l *Log) Write(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(line, '\n'))
+	return err
+}
+
+// Filter selects entries, zero fields match everything
+type Filter struct {
+	Actor  string
+	Action string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Match reports whether the entry is selected by the filter
+func (f Filter) Match(e Entry) bool {
+	if f.Actor != "" && !strings.EqualFold(f.Actor, e.Actor) {
+		return false
+	}
+	if f.Action != "" && f.Action != e.Action {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns the entries of a JSON lines log that match the filter
+func Read(r io.Reader, f Filter) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("Error: The audit log is corrupted at line %d.", line)
+		}
+		if f.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ParseTime parses a filter bound given as RFC 3339, or as a local date with an optional time
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Error: Invalid time %s. Use YYYY-MM-DD, YYYY-MM-DDThh:mm:ss or RFC 3339.", value)
+}
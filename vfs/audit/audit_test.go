@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogAndRead(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewLog(&buf)
+
+	base := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: base, Actor: "alice", Action: "register", Target: "alice", Result: ResultOK},
+		{Time: base.Add(time.Hour), Actor: "alice", Action: "create-folder", Target: "alice/docs", Args: map[string]string{"description": ""}, Result: ResultOK},
+		{Time: base.Add(2 * time.Hour), Actor: "bob", Action: "create-folder", Target: "bob/docs", Result: ResultError, Error: "Error: bob does not exist."},
+	}
+	for _, e := range entries {
+		if err := log.Write(e); err != nil {
+			t.Fatalf("Expected no error but got '%s'", err)
+		}
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != len(entries) {
+		t.Errorf("Expected %d lines but got %d", len(entries), lines)
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected int
+	}{
+		{name: "no filter", filter: Filter{}, expected: 3},
+		{name: "by actor", filter: Filter{Actor: "ALICE"}, expected: 2},
+		{name: "by action", filter: Filter{Action: "create-folder"}, expected: 2},
+		{name: "by actor and action", filter: Filter{Actor: "bob", Action: "create-folder"}, expected: 1},
+		{name: "since", filter: Filter{Since: base.Add(time.Hour)}, expected: 2},
+		{name: "until", filter: Filter{Until: base.Add(time.Hour)}, expected: 2},
+		{name: "time range", filter: Filter{Since: base.Add(30 * time.Minute), Until: base.Add(90 * time.Minute)}, expected: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := Read(strings.NewReader(buf.String()), test.filter)
+			if err != nil {
+				t.Fatalf("Expected no error but got '%s'", err)
+			}
+			if len(result) != test.expected {
+				t.Errorf("Expected %d entries but got %d", test.expected, len(result))
+			}
+		})
+	}
+
+	// Test reading a corrupted log
+	_, err := Read(strings.NewReader("{not json}\n"), Filter{})
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{name: "date", input: "2023-05-01", expected: time.Date(2023, 5, 1, 0, 0, 0, 0, time.Local)},
+		{name: "date and time", input: "2023-05-01T10:30:00", expected: time.Date(2023, 5, 1, 10, 30, 0, 0, time.Local)},
+		{name: "rfc3339", input: "2023-05-01T10:30:00Z", expected: time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "invalid", input: "yesterday", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParseTime(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expected error %v but got '%v'", test.wantErr, err)
+			}
+			if !result.Equal(test.expected) {
+				t.Errorf("Expected %v but got %v for input %s", test.expected, result, test.input)
+			}
+		})
+	}
+}
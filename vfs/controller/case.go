@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"fmt"
+	"iscool/vfs/controller/validate"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CaseMode decides how folder and file names differing only by case are told apart.
+// Usernames always ignore case.
+type CaseMode int
+
+const (
+	// CaseSensitive keeps Docs and docs apart
+	CaseSensitive CaseMode = iota
+	// CaseInsensitive stores names in lower case and finds them whatever their case
+	CaseInsensitive
+	// CasePreserving stores names as they are given but finds them whatever their case
+	CasePreserving
+)
+
+// String returns the name of the case mode
+func (m CaseMode) String() string {
+	switch m {
+	case CaseSensitive:
+		return "sensitive"
+	case CaseInsensitive:
+		return "insensitive"
+	case CasePreserving:
+		return "preserving"
+	}
+	return "unknown"
+}
+
+// ParseCaseMode returns the case mode with the given name
+func ParseCaseMode(name string) (CaseMode, error) {
+	for _, m := range []CaseMode{CaseSensitive, CaseInsensitive, CasePreserving} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return CaseSensitive, fmt.Errorf("Error: Unknown case mode %s. Valid modes are 'sensitive' 'insensitive' 'preserving'", name)
+}
+
+// WithCaseMode sets the case mode of folder and file names
+func WithCaseMode(mode CaseMode) Option {
+	return func(fs *FileSystem) {
+		fs.caseMode = mode
+	}
+}
+
+// CaseMode returns the case mode of folder and file names
+func (fs *FileSystem) CaseMode() CaseMode {
+	if err := fs.rlock(); err != nil {
+		return CaseSensitive
+	}
+	defer fs.runlock()
+	return fs.caseMode
+}
+
+// name returns the name to store for a normalized name
+func (m CaseMode) name(name string) string {
+	if m == CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// key returns the map key of a name, names with the same key being the same folder or file
+func (m CaseMode) key(name string) string {
+	name = validate.Normalize(name)
+	if m != CaseSensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// match reports whether the name matches the path.Match pattern
+func (m CaseMode) match(pattern, name string) bool {
+	if m != CaseSensitive {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// CaseCollision lists folders of a user, or files of a folder when Folder is set,
+// that would have the same name in another case mode
+type CaseCollision struct {
+	Username string
+	Folder   string
+	Names    []string
+}
+
+// String formats the collision for display
+func (c CaseCollision) String() string {
+	prefix := c.Username + "/"
+	if c.Folder != "" {
+		prefix += c.Folder + "/"
+	}
+	paths := make([]string, len(c.Names))
+	for i, name := range c.Names {
+		paths[i] = prefix + name
+	}
+	return strings.Join(paths, " ")
+}
+
+// CaseCollisions returns the names that would collide after switching to the mode,
+// sorted by path. Switching is only possible when there are none.
+func (fs *FileSystem) CaseCollisions(mode CaseMode) (collisions []CaseCollision, err error) {
+	call := Call{Operation: "case-collisions"}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return nil, err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return nil, err
+	}
+	return fs.caseCollisions(mode), nil
+}
+
+func (fs *FileSystem) caseCollisions(mode CaseMode) []CaseCollision {
+	var collisions []CaseCollision
+	for _, user := range fs.Users {
+		folderNames := make(map[string][]string)
+		for _, folder := range user.Folders {
+			key := mode.key(folder.Name)
+			folderNames[key] = append(folderNames[key], folder.Name)
+
+			fileNames := make(map[string][]string)
+			for _, file := range folder.Files {
+				key := mode.key(file.Name)
+				fileNames[key] = append(fileNames[key], file.Name)
+			}
+			for _, names := range fileNames {
+				if len(names) > 1 {
+					sort.Strings(names)
+					collisions = append(collisions, CaseCollision{Username: user.Name, Folder: folder.Name, Names: names})
+				}
+			}
+		}
+		for _, names := range folderNames {
+			if len(names) > 1 {
+				sort.Strings(names)
+				collisions = append(collisions, CaseCollision{Username: user.Name, Names: names})
+			}
+		}
+	}
+
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].String() < collisions[j].String()
+	})
+	return collisions
+}
+
+// SetCaseMode switches the case mode of every folder and file name. It fails without
+// changing anything when names would collide, which CaseCollisions reports beforehand.
+// Switching to CaseInsensitive lowers the case of every name.
+func (fs *FileSystem) SetCaseMode(mode CaseMode) (err error) {
+	call := Call{Operation: "set-case-mode", Args: map[string]string{"mode": mode.String()}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("set-case-mode", "", "", call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	if fs.tx != nil {
+		return newError(ErrTransaction, "Error: Cannot switch the case mode inside a transaction.")
+	}
+	if collisions := fs.caseCollisions(mode); len(collisions) > 0 {
+		return newError(ErrConflict, "Error: Cannot switch to %s mode, names collide in %d places.", mode, len(collisions))
+	}
+
+	fs.caseMode = mode
+	for _, user := range fs.Users {
+		folders := user.Folders
+		user.caseMode = mode
+		user.Folders = make(map[string]*Folder, len(folders))
+		for _, folder := range folders {
+			files := folder.Files
+			folder.caseMode = mode
+			folder.Files = make(map[string]*File, len(files))
+			for _, file := range files {
+				if name := mode.name(file.Name); name != file.Name {
+					fs.index.Remove(documentID(user, folder, file))
+					folder.fileIndex.update(file, func() { file.Name = name })
+					fs.indexFile(user, folder, file)
+				}
+				folder.Files[mode.key(file.Name)] = file
+			}
+
+			user.Folders[mode.key(folder.Name)] = folder
+			if name := mode.name(folder.Name); name != folder.Name {
+				fs.renameFolder(user, folder, name)
+			}
+		}
+	}
+	return nil
+}
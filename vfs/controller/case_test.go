@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaseMode(t *testing.T) {
+	tests := []struct {
+		mode         CaseMode
+		wantConflict bool
+		wantName     string
+	}{
+		{CaseSensitive, false, "Docs"},
+		{CaseInsensitive, true, "docs"},
+		{CasePreserving, true, "Docs"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode.String(), func(t *testing.T) {
+			fs := NewFileSystem(WithCaseMode(test.mode))
+			if err := fs.Register("test_user"); err != nil {
+				t.Fatalf("Failed to register user: %s", err)
+			}
+			if err := fs.CreateFolder("test_user", "Docs", ""); err != nil {
+				t.Fatalf("Failed to create folder: %s", err)
+			}
+
+			err := fs.CreateFolder("test_user", "docs", "")
+			if (err != nil) != test.wantConflict {
+				t.Errorf("Expected a conflict %v but got %v", test.wantConflict, err)
+			}
+
+			folder := fs.getUserByUsername("test_user").getFolderByName("Docs")
+			if folder == nil || folder.Name != test.wantName {
+				t.Fatalf("Expected the folder %s but got %+v", test.wantName, folder)
+			}
+			if err := fs.CreateFile("test_user", "Docs", "README.md", ""); err != nil {
+				t.Fatalf("Failed to create file: %s", err)
+			}
+			err = fs.CreateFile("test_user", "Docs", "readme.md", "")
+			if (err != nil) != test.wantConflict {
+				t.Errorf("Expected a conflict %v but got %v", test.wantConflict, err)
+			}
+			if test.mode != CaseSensitive {
+				if err := fs.WriteFile("test_user", "DOCS", "Readme.MD", []byte("hello")); err != nil {
+					t.Errorf("Expected no error but got '%s'", err.Error())
+				}
+				matches, err := fs.GlobFiles("test_user", "d*", "*.MD")
+				if err != nil || len(matches) != 1 {
+					t.Errorf("Expected one match but got %v, %v", matches, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRenameFolderCase(t *testing.T) {
+	fs := NewFileSystem(WithCaseMode(CasePreserving))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "docs", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	// Changing only the case renames the folder
+	if err := fs.RenameFolder("test_user", "docs", "Docs"); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	output, err := fs.ListFolders("test_user", "--sort-name", "asc")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if strings.Join(listNames(output), " ") != "Docs" {
+		t.Errorf("Expected Docs but got %s", output)
+	}
+}
+
+func TestSetCaseMode(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, name := range []string{"Docs", "docs", "DOCS", "Photos"} {
+		if err := fs.CreateFolder("test_user", name, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+	for _, name := range []string{"a.txt", "A.txt", "b.txt"} {
+		if err := fs.CreateFile("test_user", "Photos", name, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+
+	// Collisions are reported and prevent the switch
+	collisions, err := fs.CaseCollisions(CaseInsensitive)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if len(collisions) != 2 {
+		t.Fatalf("Expected 2 collisions but got %v", collisions)
+	}
+	if got := collisions[0].String(); got != "test_user/DOCS test_user/Docs test_user/docs" {
+		t.Errorf("Expected the folder collision but got %s", got)
+	}
+	if got := collisions[1].String(); got != "test_user/Photos/A.txt test_user/Photos/a.txt" {
+		t.Errorf("Expected the file collision but got %s", got)
+	}
+	if err := fs.SetCaseMode(CaseInsensitive); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.CreateFolder("test_user", "Photos2", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if fs.getUserByUsername("test_user").getFolderByName("photos") != nil {
+		t.Errorf("Expected the case mode to be unchanged")
+	}
+
+	// Once the collisions are resolved the names are lowered
+	for _, name := range []string{"docs", "DOCS"} {
+		if err := fs.DeleteFolder("test_user", name); err != nil {
+			t.Fatalf("Failed to delete folder: %s", err)
+		}
+	}
+	if err := fs.DeleteFile("test_user", "Photos", "A.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	if err := fs.SetCaseMode(CaseInsensitive); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+
+	output, err := fs.ListFolders("test_user", "--sort-name", "asc")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if strings.Join(listNames(output), " ") != "docs photos photos2" {
+		t.Errorf("Expected the lowered names but got %s", output)
+	}
+	if err := fs.CreateFile("test_user", "PHOTOS", "B.TXT", ""); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if out, err := fs.Search("test_user", "photos"); err != nil || out == "" {
+		t.Errorf("Expected the renamed folder to be searchable but got '%s', %v", out, err)
+	}
+}
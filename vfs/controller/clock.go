@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTimeFormat is the layout of the times listed by a FileSystem
+const DefaultTimeFormat = "2006-01-02 15:04:05"
+
+// Clock tells the time to a FileSystem
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock of a FileSystem created without WithClock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock makes the FileSystem take every timestamp from the clock
+func WithClock(clock Clock) Option {
+	return func(fs *FileSystem) {
+		fs.clock = clock
+	}
+}
+
+// WithTimeFormat sets the layout and the time zone of the times in listings.
+// A nil location keeps the location of the clock.
+func WithTimeFormat(layout string, location *time.Location) Option {
+	return func(fs *FileSystem) {
+		fs.timeFormat = layout
+		fs.location = location
+	}
+}
+
+// formatTime formats a time for listings
+func (fs *FileSystem) formatTime(t time.Time) string {
+	if fs.location != nil {
+		t = t.In(fs.location)
+	}
+	return t.Format(fs.timeFormat)
+}
+
+// FakeClock is a Clock for tests that only moves when told to.
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock stopped at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock is stopped at
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set stops the clock at now
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Errorf("Expected %s but got %s", start, clock.Now())
+	}
+	clock.Advance(time.Hour)
+	if expected := start.Add(time.Hour); !clock.Now().Equal(expected) {
+		t.Errorf("Expected %s but got %s", expected, clock.Now())
+	}
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Errorf("Expected %s but got %s", start, clock.Now())
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	start := time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	fs := NewFileSystem(WithClock(clock))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	clock.Advance(time.Hour)
+	if err := fs.WriteFile("test_user", "test_folder", "test_file", []byte("content")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	folder := fs.Users["test_user"].Folders["test_folder"]
+	file := folder.Files["test_file"]
+	if !file.CreatedAt.Equal(start) {
+		t.Errorf("Expected the file to be created at %s but got %s", start, file.CreatedAt)
+	}
+	if expected := start.Add(time.Hour); !file.ModifiedAt.Equal(expected) {
+		t.Errorf("Expected the file to be modified at %s but got %s", expected, file.ModifiedAt)
+	}
+	if !folder.ModifiedAt.Equal(start) {
+		t.Errorf("Expected the folder to be modified at %s but got %s", start, folder.ModifiedAt)
+	}
+}
+
+func TestWithTimeFormat(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	clock := NewFakeClock(time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC))
+	fs := NewFileSystem(WithClock(clock), WithTimeFormat(time.RFC3339, tokyo))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", "description"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	result, err := fs.ListFolders("test_user", "--sort-name", "asc")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expected := "test_folder description 2023-05-01T19:30:00+09:00 test_user"
+	if result != expected {
+		t.Errorf("Expected '%s' but got '%s'", expected, result)
+	}
+}
@@ -0,0 +1,53 @@
+// Package controllertest provides helpers for testing code that uses a FileSystem
+package controllertest
+
+import (
+	"iscool/vfs/controller"
+	"testing"
+	"time"
+)
+
+// Epoch is the time the clock of a FileSystem returned by New starts at
+var Epoch = time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC)
+
+// New returns a FileSystem on a fake clock stopped at Epoch, listing times in UTC.
+// The options are applied after the clock, so they can override it.
+func New(t testing.TB, opts ...controller.Option) (*controller.FileSystem, *controller.FakeClock) {
+	t.Helper()
+
+	clock := controller.NewFakeClock(Epoch)
+	opts = append([]controller.Option{
+		controller.WithClock(clock),
+		controller.WithTimeFormat(controller.DefaultTimeFormat, time.UTC),
+	}, opts...)
+	return controller.NewFileSystem(opts...), clock
+}
+
+// Register registers the users, failing the test on error
+func Register(t testing.TB, fs *controller.FileSystem, usernames ...string) {
+	t.Helper()
+
+	for _, username := range usernames {
+		if err := fs.Register(username); err != nil {
+			t.Fatalf("Failed to register user %s: %s", username, err)
+		}
+	}
+}
+
+// CreateFolder creates a folder, failing the test on error
+func CreateFolder(t testing.TB, fs *controller.FileSystem, username, foldername, description string) {
+	t.Helper()
+
+	if err := fs.CreateFolder(username, foldername, description); err != nil {
+		t.Fatalf("Failed to create folder %s: %s", foldername, err)
+	}
+}
+
+// CreateFile creates a file, failing the test on error
+func CreateFile(t testing.TB, fs *controller.FileSystem, username, foldername, filename, description string) {
+	t.Helper()
+
+	if err := fs.CreateFile(username, foldername, filename, description); err != nil {
+		t.Fatalf("Failed to create file %s: %s", filename, err)
+	}
+}
@@ -0,0 +1,45 @@
+package controllertest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	fs, clock := New(t)
+	Register(t, fs, "test_user")
+	CreateFolder(t, fs, "test_user", "test_folder", "description")
+	CreateFile(t, fs, "test_user", "test_folder", "file1.txt", "first")
+	clock.Advance(time.Second)
+	CreateFile(t, fs, "test_user", "test_folder", "file2.txt", "second")
+
+	result, err := fs.ListFiles("test_user", "test_folder", "--sort-created", "desc")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expected := "file2.txt second 2023-05-01 10:30:01 test_folder test_user\n" +
+		"file1.txt first 2023-05-01 10:30:00 test_folder test_user"
+	if result != expected {
+		t.Errorf("Expected '%s' but got '%s'", expected, result)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	fs, clock := New(t)
+	Register(t, fs, "test_user")
+
+	w, err := fs.Watch("test_user", "")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	defer w.Close()
+
+	clock.Advance(time.Minute)
+	CreateFolder(t, fs, "test_user", "test_folder", "")
+
+	event := <-w.Events
+	expected := "2023-05-01 10:31:00 created test_user/test_folder"
+	if result := event.Format("2006-01-02 15:04:05", time.UTC); result != expected {
+		t.Errorf("Expected '%s' but got '%s'", expected, result)
+	}
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"fmt"
+)
+
+// The errors returned by a FileSystem match one of these with errors.Is when they
+// belong to its class, which ErrorClass reports. Quota errors match ErrQuotaExceeded.
+var (
+	ErrNotFound      = &classError{message: "not found"}
+	ErrAlreadyExists = &classError{message: "already exists"}
+	ErrInvalidName   = &classError{message: "invalid name"}
+	ErrConflict      = &classError{message: "conflict"}
+	ErrTransaction   = &classError{message: "transaction"}
+	ErrIO            = &classError{message: "cannot read or write the host"}
+	// ErrInvalid is matched by the errors about an argument out of its range
+	ErrInvalid = &classError{message: "invalid argument"}
+	// ErrWarning is matched by the errors telling that there is nothing to do or show
+	ErrWarning = &classError{message: "warning"}
+)
+
+// classError is an error for the user whose class is one of the sentinel errors
+type classError struct {
+	class   *classError
+	message string
+}
+
+func (e *classError) Error() string {
+	return e.message
+}
+
+// Is makes errors.Is(err, class) report true for the class of the error
+func (e *classError) Is(target error) bool {
+	return e.class != nil && target == e.class
+}
+
+// newError formats an error for the user belonging to the class
+func newError(class *classError, format string, args ...any) error {
+	return &classError{class: class, message: fmt.Sprintf(format, args...)}
+}
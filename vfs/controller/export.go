@@ -0,0 +1,363 @@
+package controller
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the file of an export keeping what the host file system cannot:
+// descriptions, timestamps and metadata
+const ManifestName = ".iscool-manifest.json"
+
+// Manifest describes the folders and files of an export
+type Manifest struct {
+	Username   string           `json:"username"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Folders    []FolderManifest `json:"folders"`
+}
+
+// FolderManifest describes an exported folder
+type FolderManifest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ModifiedAt  time.Time         `json:"modified_at"`
+	Tags        []string          `json:"tags,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Files       []FileManifest    `json:"files"`
+}
+
+// FileManifest describes an exported file
+type FileManifest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ModifiedAt  time.Time         `json:"modified_at"`
+	Size        int               `json:"size"`
+	Tags        []string          `json:"tags,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+// Files counts the files of the manifest
+func (m *Manifest) Files() int {
+	count := 0
+	for _, folder := range m.Folders {
+		count += len(folder.Files)
+	}
+	return count
+}
+
+// Export writes the user's folders, or only the given folder, to dest. Each folder becomes a
+// directory holding its files, and the manifest is written next to them.
+// dest is a tar.gz archive when it ends in .tar.gz or .tgz, a zip archive when it ends in .zip,
+// and otherwise a directory, which must be empty or missing. Archives must not exist yet.
+func (fs *FileSystem) Export(username, foldername, dest string) (manifest *Manifest, err error) {
+	call := Call{Operation: "export", User: username, Folder: foldername, Args: map[string]string{"dest": dest}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.beforeUnlocked(call, false); err != nil {
+		return nil, err
+	}
+	manifest, contents, err := fs.snapshot(username, foldername)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := newExportWriter(dest)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeExport(w, manifest, contents); err != nil {
+		w.abort()
+		return nil, newError(ErrIO, "Error: Cannot export to %s: %v", dest, err)
+	}
+	return manifest, nil
+}
+
+// snapshot copies what Export writes, so the host is written without holding the lock.
+// contents is indexed like the folders and files of the manifest.
+func (fs *FileSystem) snapshot(username, foldername string) (*Manifest, [][][]byte, error) {
+	if err := fs.rlock(); err != nil {
+		return nil, nil, err
+	}
+	defer fs.runlock()
+
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil, nil, newError(ErrNotFound, "Error: %s doesn't exist.", username)
+	}
+
+	var folders []*Folder
+	if foldername != "" {
+		folder := user.getFolderByName(foldername)
+		if folder == nil {
+			return nil, nil, newError(ErrNotFound, "Error: %s doesn't exist.", foldername)
+		}
+		folders = append(folders, folder)
+	} else {
+		for _, folder := range user.Folders {
+			folders = append(folders, folder)
+		}
+		sort.Slice(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+	}
+
+	manifest := &Manifest{Username: user.Name, ExportedAt: fs.clock.Now()}
+	contents := make([][][]byte, len(folders))
+	for i, folder := range folders {
+		if err := validateHostName(folder.Name); err != nil {
+			return nil, nil, err
+		}
+		folderManifest := FolderManifest{
+			Name:        folder.Name,
+			Description: folder.Description,
+			CreatedAt:   folder.CreatedAt,
+			ModifiedAt:  folder.ModifiedAt,
+			Tags:        append([]string(nil), folder.Tags...),
+			Attributes:  copyAttributes(folder.Attributes),
+		}
+
+		files := make([]*File, 0, len(folder.Files))
+		for _, file := range folder.Files {
+			files = append(files, file)
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+		for _, file := range files {
+			if err := validateHostName(file.Name); err != nil {
+				return nil, nil, err
+			}
+			folderManifest.Files = append(folderManifest.Files, FileManifest{
+				Name:        file.Name,
+				Description: file.Description,
+				CreatedAt:   file.CreatedAt,
+				ModifiedAt:  file.ModifiedAt,
+				Size:        len(file.Content),
+				Tags:        append([]string(nil), file.Tags...),
+				Attributes:  copyAttributes(file.Attributes),
+			})
+			contents[i] = append(contents[i], append([]byte(nil), file.Content...))
+		}
+		manifest.Folders = append(manifest.Folders, folderManifest)
+	}
+	return manifest, contents, nil
+}
+
+// validateHostName refuses the names a host file system would not take as a plain entry
+func validateHostName(name string) error {
+	if name == "." || name == ".." || name == ManifestName {
+		return newError(ErrInvalidName, "Error: %s cannot be exported.", name)
+	}
+	return nil
+}
+
+func copyAttributes(attributes map[string]string) map[string]string {
+	if len(attributes) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		copied[key] = value
+	}
+	return copied
+}
+
+func writeExport(w exportWriter, manifest *Manifest, contents [][][]byte) error {
+	for i, folder := range manifest.Folders {
+		if err := w.dir(folder.Name, folder.CreatedAt); err != nil {
+			return err
+		}
+		for j, file := range folder.Files {
+			if err := w.file(path.Join(folder.Name, file.Name), contents[i][j], file.CreatedAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := w.file(ManifestName, data, manifest.ExportedAt); err != nil {
+		return err
+	}
+	return w.close()
+}
+
+// exportWriter writes the entries of an export, named with slash separated paths
+type exportWriter interface {
+	dir(name string, modTime time.Time) error
+	file(name string, content []byte, modTime time.Time) error
+	close() error
+	// abort removes what was written after a failure
+	abort()
+}
+
+func newExportWriter(dest string) (exportWriter, error) {
+	lower := strings.ToLower(dest)
+	if !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") && !strings.HasSuffix(lower, ".zip") {
+		return newDirWriter(dest)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, newError(ErrAlreadyExists, "Error: %s already exists.", dest)
+	} else if err != nil {
+		return nil, newError(ErrIO, "Error: Cannot export to %s: %v", dest, err)
+	}
+	if strings.HasSuffix(lower, ".zip") {
+		return &zipWriter{f: f, w: zip.NewWriter(f)}, nil
+	}
+	gz := gzip.NewWriter(f)
+	return &tarWriter{f: f, gz: gz, w: tar.NewWriter(gz)}, nil
+}
+
+// dirWriter exports to a directory of the host
+type dirWriter struct {
+	root    string
+	created bool
+	// dirTimes is applied on close, writing the files would change it
+	dirTimes map[string]time.Time
+}
+
+func newDirWriter(root string) (*dirWriter, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, newError(ErrIO, "Error: Cannot export to %s: %v", root, err)
+	}
+	if len(entries) > 0 {
+		return nil, newError(ErrAlreadyExists, "Error: The directory %s is not empty.", root)
+	}
+
+	created := errors.Is(err, os.ErrNotExist)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, newError(ErrIO, "Error: Cannot export to %s: %v", root, err)
+	}
+	return &dirWriter{root: root, created: created, dirTimes: make(map[string]time.Time)}, nil
+}
+
+func (w *dirWriter) dir(name string, modTime time.Time) error {
+	p := filepath.Join(w.root, filepath.FromSlash(name))
+	if err := os.Mkdir(p, 0755); err != nil {
+		return err
+	}
+	w.dirTimes[p] = modTime
+	return nil
+}
+
+func (w *dirWriter) file(name string, content []byte, modTime time.Time) error {
+	p := filepath.Join(w.root, filepath.FromSlash(name))
+	if err := os.WriteFile(p, content, 0644); err != nil {
+		return err
+	}
+	return os.Chtimes(p, modTime, modTime)
+}
+
+func (w *dirWriter) close() error {
+	for p, modTime := range w.dirTimes {
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *dirWriter) abort() {
+	if w.created {
+		os.RemoveAll(w.root)
+		return
+	}
+	// The directory was empty, only the export is removed
+	entries, _ := os.ReadDir(w.root)
+	for _, entry := range entries {
+		os.RemoveAll(filepath.Join(w.root, entry.Name()))
+	}
+}
+
+// tarWriter exports to a gzip compressed tar archive
+type tarWriter struct {
+	f  *os.File
+	gz *gzip.Writer
+	w  *tar.Writer
+}
+
+func (w *tarWriter) dir(name string, modTime time.Time) error {
+	return w.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  modTime,
+	})
+}
+
+func (w *tarWriter) file(name string, content []byte, modTime time.Time) error {
+	err := w.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(content)
+	return err
+}
+
+func (w *tarWriter) close() error {
+	if err := w.w.Close(); err != nil {
+		return err
+	}
+	if err := w.gz.Close(); err != nil {
+		return err
+	}
+	return w.f.Close()
+}
+
+func (w *tarWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// zipWriter exports to a zip archive
+type zipWriter struct {
+	f *os.File
+	w *zip.Writer
+}
+
+func (w *zipWriter) dir(name string, modTime time.Time) error {
+	header := &zip.FileHeader{Name: name + "/", Modified: modTime}
+	header.SetMode(os.ModeDir | 0755)
+	_, err := w.w.CreateHeader(header)
+	return err
+}
+
+func (w *zipWriter) file(name string, content []byte, modTime time.Time) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	header.SetMode(0644)
+	out, err := w.w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(content)
+	return err
+}
+
+func (w *zipWriter) close() error {
+	if err := w.w.Close(); err != nil {
+		return err
+	}
+	return w.f.Close()
+}
+
+func (w *zipWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
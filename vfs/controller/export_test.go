@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newExportFileSystem returns a user with two folders, one of them holding two files
+func newExportFileSystem(t *testing.T) (*FileSystem, time.Time) {
+	start := time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	fs := NewFileSystem(WithClock(clock))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "docs", "the docs"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "empty", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	clock.Advance(time.Hour)
+	if err := fs.CreateFile("test_user", "docs", "a.txt", "first file"); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.WriteFile("test_user", "docs", "a.txt", []byte("hello")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "docs", "b.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.AddTag("test_user", "docs", "a.txt", "draft"); err != nil {
+		t.Fatalf("Failed to tag file: %s", err)
+	}
+	return fs, start
+}
+
+func TestExportDirectory(t *testing.T) {
+	fs, start := newExportFileSystem(t)
+	dest := filepath.Join(t.TempDir(), "out")
+
+	manifest, err := fs.Export("test_user", "", dest)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if len(manifest.Folders) != 2 || manifest.Files() != 2 {
+		t.Errorf("Expected 2 folders and 2 files but got %d and %d", len(manifest.Folders), manifest.Files())
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, "docs", "a.txt"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Expected 'hello' but got '%s' (%v)", content, err)
+	}
+	info, err := os.Stat(filepath.Join(dest, "docs", "a.txt"))
+	if err != nil || !info.ModTime().Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the file to be dated %s but got %v (%v)", start.Add(time.Hour), info, err)
+	}
+	info, err = os.Stat(filepath.Join(dest, "empty"))
+	if err != nil || !info.IsDir() || !info.ModTime().Equal(start) {
+		t.Errorf("Expected a directory dated %s but got %v (%v)", start, info, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, ManifestName))
+	if err != nil {
+		t.Fatalf("Expected a manifest but got '%s'", err)
+	}
+	var read Manifest
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatalf("Expected a valid manifest but got '%s'", err)
+	}
+	docs := read.Folders[0]
+	if docs.Name != "docs" || docs.Description != "the docs" || !docs.CreatedAt.Equal(start) {
+		t.Errorf("Expected the docs folder in the manifest but got %+v", docs)
+	}
+	if file := docs.Files[0]; file.Name != "a.txt" || file.Description != "first file" || file.Size != 5 || len(file.Tags) != 1 {
+		t.Errorf("Expected a.txt in the manifest but got %+v", file)
+	}
+
+	// Test exporting to a directory that isn't empty
+	_, err = fs.Export("test_user", "", dest)
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected an already exists error but got '%v'", err)
+	}
+}
+
+func TestExportFolder(t *testing.T) {
+	fs, _ := newExportFileSystem(t)
+	dest := t.TempDir()
+
+	// Test exporting a folder that doesn't exist
+	_, err := fs.Export("test_user", "missing", dest)
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	manifest, err := fs.Export("test_user", "docs", dest)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if len(manifest.Folders) != 1 {
+		t.Errorf("Expected 1 folder but got %d", len(manifest.Folders))
+	}
+	if _, err := os.Stat(filepath.Join(dest, "empty")); !os.IsNotExist(err) {
+		t.Errorf("Expected only the docs folder to be exported")
+	}
+}
+
+func TestExportTarGz(t *testing.T) {
+	fs, start := newExportFileSystem(t)
+	dest := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	if _, err := fs.Export("test_user", "", dest); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+
+	f, err := os.Open(dest)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %s", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %s", err)
+	}
+	r := tar.NewReader(gz)
+
+	var names []string
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %s", err)
+		}
+		names = append(names, header.Name)
+		if header.Name == "docs/a.txt" {
+			content, _ := io.ReadAll(r)
+			if string(content) != "hello" {
+				t.Errorf("Expected 'hello' but got '%s'", content)
+			}
+			if !header.ModTime.Equal(start.Add(time.Hour)) {
+				t.Errorf("Expected the file to be dated %s but got %s", start.Add(time.Hour), header.ModTime)
+			}
+		}
+	}
+	expected := []string{ManifestName, "docs/", "docs/a.txt", "docs/b.txt", "empty/"}
+	sort.Strings(names)
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v but got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected %v but got %v", expected, names)
+			break
+		}
+	}
+
+	// Test exporting to an archive that already exists
+	_, err = fs.Export("test_user", "", dest)
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestExportZip(t *testing.T) {
+	fs, start := newExportFileSystem(t)
+	dest := filepath.Join(t.TempDir(), "out.zip")
+
+	if _, err := fs.Export("test_user", "", dest); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+
+	r, err := zip.OpenReader(dest)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %s", err)
+	}
+	defer r.Close()
+
+	found := false
+	for _, f := range r.File {
+		if f.Name != "docs/a.txt" {
+			continue
+		}
+		found = true
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to read archive: %s", err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(content) != "hello" {
+			t.Errorf("Expected 'hello' but got '%s'", content)
+		}
+		if !f.Modified.Equal(start.Add(time.Hour)) {
+			t.Errorf("Expected the file to be dated %s but got %s", start.Add(time.Hour), f.Modified)
+		}
+	}
+	if !found {
+		t.Errorf("Expected docs/a.txt in the archive")
+	}
+}
+
+func TestExportHostNames(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "..", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	_, err := fs.Export("test_user", "", filepath.Join(t.TempDir(), "out"))
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
@@ -1,134 +1,367 @@
 package controller
 
 import (
+	"bytes"
 	"fmt"
 	"iscool/vfs/controller/validate"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // CreateFile creates a new file in the specified folder for the user
-func (fs *FileSystem) CreateFile(username, foldername, filename, description string) error {
+func (fs *FileSystem) CreateFile(username, foldername, filename, description string) (err error) {
+	call := Call{Operation: "create-file", User: username, Folder: foldername, File: filename, Args: map[string]string{"description": description}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("create-file", username, username+"/"+foldername+"/"+filename, call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
 	user := fs.getUserByUsername(username)
 	if user == nil {
-		return fmt.Errorf("Error: The %s doesn't exist.", username)
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", username)
 	}
 
 	folder := user.getFolderByName(foldername)
 	if folder == nil {
-		return fmt.Errorf("Error: The %s doesn't exist.", foldername)
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
 	}
 
-	if validate.ValidateNoInvalidChars(filename) {
-		return fmt.Errorf("Error: The %s contains invalid chars.", filename)
+	filename = folder.caseMode.name(validate.Normalize(filename))
+	if err := fs.validateName(entityFile, filename); err != nil {
+		return err
 	}
 
-	if validate.ValidateLength(filename, 255) {
-		return fmt.Errorf("Error: Filename be under %d characters.", 255)
+	if folder.isFileExists(filename) {
+		return newError(ErrAlreadyExists, "Error: The %s has already existed.", filename)
 	}
 
-	if folder.isFileExists(filename) {
-		return fmt.Errorf("Error: The %s has already existed.", filename)
+	if err := fs.checkQuota(user, 0, 1, 0); err != nil {
+		return err
 	}
 
-	now := time.Now()
+	now := fs.clock.Now()
 	file := &File{
 		Name:        filename,
 		Description: description,
 		CreatedAt:   now,
+		ModifiedAt:  now,
+		AccessedAt:  now,
 	}
 
-	folder.Files[filename] = file
+	fs.addFile(user, folder, file)
+	fs.recordChange("create-file "+username+"/"+foldername+"/"+filename, func() bool {
+		// Content written since would be lost
+		if !fs.hasFile(user, folder, file) || len(file.Content) > 0 {
+			return false
+		}
+		fs.removeFile(user, folder, file)
+		return true
+	}, func() bool {
+		if !fs.hasFolder(user, folder) || folder.isFileExists(file.Name) || !fs.withinQuota(user, 0, 1, int64(len(file.Content))) {
+			return false
+		}
+		fs.addFile(user, folder, file)
+		return true
+	})
 
 	return nil
 }
 
 // DeleteFile deletes the specified file from the folder for the user
-func (fs *FileSystem) DeleteFile(username, foldername, filename string) error {
+func (fs *FileSystem) DeleteFile(username, foldername, filename string) (err error) {
+	call := Call{Operation: "delete-file", User: username, Folder: foldername, File: filename}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("delete-file", username, username+"/"+foldername+"/"+filename, nil, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+
+	folder := user.getFolderByName(foldername)
+	if folder == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
+	}
+
+	file := folder.getFileByName(filename)
+	if file == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", filename)
+	}
+	fs.removeFile(user, folder, file)
+	fs.recordChange("delete-file "+username+"/"+foldername+"/"+filename, func() bool {
+		if !fs.hasFolder(user, folder) || folder.isFileExists(file.Name) || !fs.withinQuota(user, 0, 1, int64(len(file.Content))) {
+			return false
+		}
+		fs.addFile(user, folder, file)
+		return true
+	}, func() bool {
+		if !fs.hasFile(user, folder, file) {
+			return false
+		}
+		fs.removeFile(user, folder, file)
+		return true
+	})
+	return nil
+}
+
+// SetFileDescription replaces the description of the specified file, an empty description clears it
+func (fs *FileSystem) SetFileDescription(username, foldername, filename, description string) (err error) {
+	call := Call{Operation: "set-file-description", User: username, Folder: foldername, File: filename, Args: map[string]string{"description": description}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("set-file-description", username, username+"/"+foldername+"/"+filename, call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
 	user := fs.getUserByUsername(username)
 	if user == nil {
-		return fmt.Errorf("Error: The %s doesn't exist.", username)
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", username)
 	}
 
 	folder := user.getFolderByName(foldername)
 	if folder == nil {
-		return fmt.Errorf("Error: The %s doesn't exist.", foldername)
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
+	}
+
+	file := folder.getFileByName(filename)
+	if file == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", filename)
 	}
 
-	if !folder.isFileExists(filename) {
-		return fmt.Errorf("Error: The %s doesn't exist.", filename)
+	if file.Description == description {
+		return nil
 	}
-	delete(folder.Files, filename)
+	previous := file.Description
+	fs.setFileDescription(user, folder, file, description)
+	fs.recordChange("set-file-description "+username+"/"+foldername+"/"+filename, func() bool {
+		if !fs.hasFile(user, folder, file) || file.Description != description {
+			return false
+		}
+		fs.setFileDescription(user, folder, file, previous)
+		return true
+	}, func() bool {
+		if !fs.hasFile(user, folder, file) || file.Description != previous {
+			return false
+		}
+		fs.setFileDescription(user, folder, file, description)
+		return true
+	})
 	return nil
 }
 
+// WriteFile replaces the content of the specified file
+func (fs *FileSystem) WriteFile(username, foldername, filename string, content []byte) (err error) {
+	call := Call{Operation: "write-file", User: username, Folder: foldername, File: filename, Args: map[string]string{"size": strconv.Itoa(len(content))}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("write-file", username, username+"/"+foldername+"/"+filename, call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+
+	folder := user.getFolderByName(foldername)
+	if folder == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
+	}
+
+	file := folder.getFileByName(filename)
+	if file == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", filename)
+	}
+
+	delta := int64(len(content) - len(file.Content))
+	if err := fs.checkQuota(user, 0, 0, delta); err != nil {
+		return err
+	}
+
+	previous := file.Content
+	written := append([]byte(nil), content...)
+	fs.writeFile(user, folder, file, written)
+	fs.recordChange("write-file "+username+"/"+foldername+"/"+filename, func() bool {
+		if !fs.hasFile(user, folder, file) || !bytes.Equal(file.Content, written) || !fs.withinQuota(user, 0, 0, -delta) {
+			return false
+		}
+		fs.writeFile(user, folder, file, previous)
+		return true
+	}, func() bool {
+		if !fs.hasFile(user, folder, file) || !bytes.Equal(file.Content, previous) || !fs.withinQuota(user, 0, 0, delta) {
+			return false
+		}
+		fs.writeFile(user, folder, file, written)
+		return true
+	})
+	return nil
+}
+
+// ReadFile returns the content of the specified file
+func (fs *FileSystem) ReadFile(username, foldername, filename string) (content []byte, err error) {
+	call := Call{Operation: "read-file", User: username, Folder: foldername, File: filename}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return nil, err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return nil, err
+	}
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil, newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+
+	folder := user.getFolderByName(foldername)
+	if folder == nil {
+		return nil, newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
+	}
+
+	file := folder.getFileByName(filename)
+	if file == nil {
+		return nil, newError(ErrNotFound, "Error: The %s doesn't exist.", filename)
+	}
+	fs.markAccessed(&file.AccessedAt)
+	return append([]byte(nil), file.Content...), nil
+}
+
 // ListFiles lists all the files in the specified folder for the user
-func (fs *FileSystem) ListFiles(username, foldername, sortBy, sortOrder string) (string, error) {
+func (fs *FileSystem) ListFiles(username, foldername, sortBy, sortOrder string, opts ...ListOption) (string, error) {
+	output, _, err := fs.ListFilesPage(username, foldername, sortBy, sortOrder, opts...)
+	return output, err
+}
+
+// ListFilesPage lists the files like ListFiles and also returns the cursor of
+// the next page, which is empty on the last page
+func (fs *FileSystem) ListFilesPage(username, foldername, sortBy, sortOrder string, opts ...ListOption) (listing, next string, err error) {
+	call := Call{Operation: "list-files", User: username, Folder: foldername}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return "", "", err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return "", "", err
+	}
+	options := newListOptions(opts)
+
 	user := fs.getUserByUsername(username)
 	if user == nil {
-		return "", fmt.Errorf("Error: The %s doesn't exist.", username)
+		return "", "", newError(ErrNotFound, "Error: The %s doesn't exist.", username)
 	}
 
 	folder := user.getFolderByName(foldername)
 	if folder == nil {
-		return "", fmt.Errorf("Error: The %s doesn't exist.", foldername)
+		return "", "", newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
 	}
 
 	files := folder.Files
 	if len(files) == 0 {
-		return "", fmt.Errorf("Warning: The folder is empty")
+		return "", "", newError(ErrWarning, "Warning: The folder is empty")
 	}
 
-	switch sortBy + " " + sortOrder {
-	case "--sort-name asc", "--sort-name desc", "--sort-created asc", "--sort-created desc", " ":
-	default:
+	if err := validateSort(sortBy, sortOrder); err != nil {
 		// suggest a valid flag to the user
-		return "", fmt.Errorf("Usage: list-folders [username] [--sort-name|--sort-created] [asc|desc]")
-	}
-
-	// Create a slice to store the file information
-	fileInfo := make([]*File, 0, len(files))
-
-	// Append the file information to the slice
-	for _, file := range files {
-		fileInfo = append(fileInfo, file)
-	}
-
-	// Sort the fileInfo based on the selected sorting option
-	switch sortBy {
-	case "--sort-name":
-		sort.Slice(fileInfo, func(i, j int) bool {
-			if sortOrder == "asc" {
-				return fileInfo[i].Name < fileInfo[j].Name
-			}
-			return fileInfo[i].Name > fileInfo[j].Name
-		})
-	case "--sort-created":
-		sort.Slice(fileInfo, func(i, j int) bool {
-			if sortOrder == "asc" {
-				return fileInfo[i].CreatedAt.Before(fileInfo[j].CreatedAt)
-			}
-			return fileInfo[i].CreatedAt.After(fileInfo[j].CreatedAt)
-		})
-	default:
-		// sort by name asc by default
-		sort.Slice(fileInfo, func(i, j int) bool {
-			return fileInfo[i].Name < fileInfo[j].Name
-		})
+		return "", "", err
+	}
+
+	// Walk the files in the selected sorting order and keep the requested page
+	fileInfo, next, err := page(&folder.fileIndex, sortBy, sortOrder, options)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(fileInfo) == 0 && options.tag != "" && options.after == "" {
+		return "", "", newError(ErrWarning, "Warning: No files are tagged %s.", options.tag)
 	}
+	if len(fileInfo) == 0 {
+		return "", "", newError(ErrWarning, "Warning: No more files to list.")
+	}
+
+	fs.markAccessed(&folder.AccessedAt)
 
 	var output []string
 	// Print the file information in the specified format
 	for _, file := range fileInfo {
-		output = append(output, fmt.Sprintf("%s %s %s %s %s", file.Name, file.Description, file.CreatedAt.Format("2006-01-02 15:04:05"), foldername, username))
+		output = append(output, fmt.Sprintf("%s %s %s %s %s", file.Name, file.Description, fs.formatTime(file.CreatedAt), foldername, username))
 	}
 	result := strings.Join(output, "\n")
-	return result, nil
+	return result, next, nil
+}
+
+// addFile attaches a new or previously removed file to the folder
+func (fs *FileSystem) addFile(user *User, folder *Folder, file *File) {
+	folder.Files[folder.caseMode.key(file.Name)] = file
+	folder.fileIndex.insert(file)
+	user.folderIndex.update(folder, func() { folder.ModifiedAt = fs.clock.Now() })
+	fs.indexFile(user, folder, file)
+	fs.adjustUsage(user, 0, 1, int64(len(file.Content)))
+	fs.notify(user, folder, Event{Type: EventCreated, Folder: folder.Name, File: file.Name})
+}
+
+// removeFile detaches the file from the folder
+func (fs *FileSystem) removeFile(user *User, folder *Folder, file *File) {
+	fs.index.Remove(documentID(user, folder, file))
+	delete(folder.Files, folder.caseMode.key(file.Name))
+	folder.fileIndex.remove(file)
+	user.folderIndex.update(folder, func() { folder.ModifiedAt = fs.clock.Now() })
+	fs.adjustUsage(user, 0, -1, -int64(len(file.Content)))
+	fs.notify(user, folder, Event{Type: EventDeleted, Folder: folder.Name, File: file.Name})
+}
+
+// setFileDescription replaces the description of the file
+func (fs *FileSystem) setFileDescription(user *User, folder *Folder, file *File, description string) {
+	file.Description = description
+	folder.fileIndex.update(file, func() { file.ModifiedAt = fs.clock.Now() })
+	fs.indexFile(user, folder, file)
+	fs.notify(user, folder, Event{Type: EventDescriptionChanged, Folder: folder.Name, File: file.Name})
+}
+
+// writeFile replaces the content of the file, which must not be shared with the caller
+func (fs *FileSystem) writeFile(user *User, folder *Folder, file *File, content []byte) {
+	fs.adjustUsage(user, 0, 0, int64(len(content)-len(file.Content)))
+	file.Content = content
+	folder.fileIndex.update(file, func() { file.ModifiedAt = fs.clock.Now() })
+	fs.indexFile(user, folder, file)
+}
+
+// getFileByName returns the specified file in the folder
+func (f *Folder) getFileByName(filename string) *File {
+	return f.Files[f.caseMode.key(filename)]
 }
 
 // isFileExists checks if the file exists in the folder
 func (f *Folder) isFileExists(filename string) bool {
-	_, exists := f.Files[filename]
+	_, exists := f.Files[f.caseMode.key(filename)]
 	return exists
 }
+
+// markAccessed sets an access time, which calls holding only the read lock update
+func (fs *FileSystem) markAccessed(accessedAt *time.Time) {
+	now := fs.clock.Now()
+	fs.accessMu.Lock()
+	*accessedAt = now
+	fs.accessMu.Unlock()
+}
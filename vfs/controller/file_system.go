@@ -1,7 +1,89 @@
 package controller
 
-func NewFileSystem() *FileSystem {
-	return &FileSystem{
-		Users: make(map[string]*User),
+import (
+	"iscool/vfs/audit"
+	"iscool/vfs/search"
+	"log/slog"
+	"time"
+)
+
+// Option configures a FileSystem
+type Option func(*FileSystem)
+
+// WithAuditLog records every mutating call, successful or not, to the log
+func WithAuditLog(log *audit.Log) Option {
+	return func(fs *FileSystem) {
+		fs.auditLog = log
+	}
+}
+
+func NewFileSystem(opts ...Option) *FileSystem {
+	fs := &FileSystem{state: &state{
+		Users:      make(map[string]*User),
+		index:      search.NewIndex(),
+		policy:     DefaultValidationPolicy(),
+		clock:      systemClock{},
+		timeFormat: DefaultTimeFormat,
+		logger:     slog.New(discardHandler{}),
+	}}
+	for _, opt := range opts {
+		opt(fs)
+	}
+	return fs
+}
+
+// recordAudit writes the outcome of a mutating call to the audit log, if any.
+// It is meant to be deferred with a pointer to the named error result. The calls
+// of a transaction are written when it is committed, and are dropped with its changes
+// when it is rolled back.
+func (fs *FileSystem) recordAudit(action, actor, target string, args map[string]string, err *error) {
+	if fs.auditLog == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		Time:   fs.clock.Now(),
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		Args:   args,
+		Result: audit.ResultOK,
+	}
+	if *err != nil {
+		entry.Result = audit.ResultError
+		entry.Error = (*err).Error()
+	}
+	if fs.tx != nil && !fs.tx.done {
+		fs.tx.audit = append(fs.tx.audit, entry)
+		return
+	}
+	fs.writeAudit(entry)
+}
+
+func (fs *FileSystem) writeAudit(entry audit.Entry) {
+	// A failing audit log must not turn a completed call into a failed one
+	if err := fs.auditLog.Write(entry); err != nil {
+		fs.logger.Error("audit log write failed", "operation", entry.Action, "error", err)
+	}
+}
+
+// observe counts a call by its result, records its latency, logs it and runs the after
+// hooks. start is taken when the call begins by deferring observe.
+func (fs *FileSystem) observe(call Call, start time.Time, err *error) {
+	duration := fs.clock.Now().Sub(start)
+	if fs.metrics != nil {
+		result := audit.ResultOK
+		if *err != nil {
+			result = audit.ResultError
+		}
+		fs.metrics.operations.Inc(call.Operation, result)
+		fs.metrics.latency.Observe(duration.Seconds(), call.Operation)
+	}
+	fs.logCall(call, duration, *err)
+	fs.after(call, *err)
+	// observe is the last to run, so the rollback follows the failure that caused it
+	if fs.tx != nil && fs.tx.failed {
+		fs.tx.failed = false
+		fs.tx.recordRollback()
 	}
 }
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"bytes"
+	"iscool/vfs/audit"
 	"testing"
 )
 
@@ -15,3 +17,113 @@ func TestNewFileSystem(t *testing.T) {
 		t.Errorf("Expected a non-nil Users map but got nil")
 	}
 }
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	fs := NewFileSystem(WithAuditLog(audit.NewLog(&buf)))
+
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", "test_description"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	// Failed attempts are recorded too
+	if err := fs.DeleteFolder("test_user", "non_existent_folder"); err == nil {
+		t.Fatalf("Expected an error but got nil")
+	}
+	// Read-only calls are not recorded
+	if _, err := fs.ListFolders("test_user", "", ""); err != nil {
+		t.Fatalf("Failed to list folders: %s", err)
+	}
+
+	entries, err := audit.Read(&buf, audit.Filter{})
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries but got %d", len(entries))
+	}
+
+	expected := []audit.Entry{
+		{Actor: "test_user", Action: "register", Target: "test_user", Result: audit.ResultOK},
+		{Actor: "test_user", Action: "create-folder", Target: "test_user/test_folder", Result: audit.ResultOK},
+		{Actor: "test_user", Action: "delete-folder", Target: "test_user/non_existent_folder", Result: audit.ResultError},
+	}
+	for i, want := range expected {
+		got := entries[i]
+		if got.Actor != want.Actor || got.Action != want.Action || got.Target != want.Target || got.Result != want.Result {
+			t.Errorf("Expected %+v but got %+v", want, got)
+		}
+	}
+	if entries[1].Args["description"] != "test_description" {
+		t.Errorf("Expected the description argument to be recorded but got %v", entries[1].Args)
+	}
+	if entries[2].Error == "" {
+		t.Errorf("Expected the error to be recorded")
+	}
+}
+
+func TestAuditLogTransaction(t *testing.T) {
+	var buf bytes.Buffer
+	fs := NewFileSystem(WithAuditLog(audit.NewLog(&buf)))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+
+	// The calls of a committed transaction are written on commit
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %s", err)
+	}
+	if err := tx.CreateFolder("test_user", "a", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatalf("Expected the registration to be written")
+	}
+	written := buf.Len()
+	if err := tx.CreateFolder("test_user", "b", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if buf.Len() != written {
+		t.Errorf("Expected the calls to be held back until the commit")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %s", err)
+	}
+
+	// A failing call drops the calls before it and records the rollback
+	tx, err = fs.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %s", err)
+	}
+	if err := tx.CreateFolder("test_user", "c", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := tx.CreateFolder("test_user", "c", ""); err == nil {
+		t.Fatalf("Expected an error but got nil")
+	}
+
+	entries, err := audit.Read(&buf, audit.Filter{})
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	expected := []audit.Entry{
+		{Action: "register", Target: "test_user", Result: audit.ResultOK},
+		{Action: "create-folder", Target: "test_user/a", Result: audit.ResultOK},
+		{Action: "create-folder", Target: "test_user/b", Result: audit.ResultOK},
+		{Action: "commit", Result: audit.ResultOK},
+		{Action: "create-folder", Target: "test_user/c", Result: audit.ResultError},
+		{Action: "rollback", Result: audit.ResultOK},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries but got %+v", len(expected), entries)
+	}
+	for i, want := range expected {
+		got := entries[i]
+		if got.Action != want.Action || got.Target != want.Target || got.Result != want.Result {
+			t.Errorf("Expected %+v but got %+v", want, got)
+		}
+	}
+}
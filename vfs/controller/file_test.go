@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -104,9 +106,68 @@ func TestDeleteFile(t *testing.T) {
 	}
 }
 
-func TestListFiles(t *testing.T) {
+func TestWriteFile(t *testing.T) {
 	fs := NewFileSystem()
 
+	// Create a user, a folder, and a file
+	err := fs.Register("test_user")
+	if err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	err = fs.CreateFolder("test_user", "test_folder", "test_description")
+	if err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	err = fs.CreateFile("test_user", "test_folder", "test_file.txt", "This is a test file.")
+	if err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	// A new file is empty
+	content, err := fs.ReadFile("test_user", "test_folder", "test_file.txt")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	if len(content) != 0 {
+		t.Errorf("Expected an empty file but got '%s'", content)
+	}
+
+	// Write and read back the content
+	err = fs.WriteFile("test_user", "test_folder", "test_file.txt", []byte("hello world"))
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	content, err = fs.ReadFile("test_user", "test_folder", "test_file.txt")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	if string(content) != "hello world" {
+		t.Errorf("Expected 'hello world' but got '%s'", content)
+	}
+
+	// Try to write a non-existent file
+	err = fs.WriteFile("test_user", "test_folder", "non_existent_file.txt", []byte("hello"))
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Try to read a file from a non-existent folder
+	_, err = fs.ReadFile("test_user", "non_existent_folder", "test_file.txt")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Try to read a file for a non-existent user
+	_, err = fs.ReadFile("non_existent_user", "test_folder", "test_file.txt")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	clock := NewFakeClock(time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC))
+	fs := NewFileSystem(WithClock(clock))
+
 	// Create a user, a folder, and some files
 	err := fs.Register("test_user")
 	if err != nil {
@@ -120,10 +181,12 @@ func TestListFiles(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create file: %s", err)
 	}
+	clock.Advance(time.Minute)
 	err = fs.CreateFile("test_user", "test_folder", "file2.txt", "This is file 2.")
 	if err != nil {
 		t.Fatalf("Failed to create file: %s", err)
 	}
+	clock.Advance(time.Minute)
 	err = fs.CreateFile("test_user", "test_folder", "file3.txt", "This is file 3.")
 	if err != nil {
 		t.Fatalf("Failed to create file: %s", err)
@@ -134,9 +197,9 @@ func TestListFiles(t *testing.T) {
 	if err != nil {
 		t.Errorf("Expected no error but got '%s'", err.Error())
 	}
-	expected := "file1.txt This is file 1. " + time.Now().Format("2006-01-02 15:04:05") + " test_folder test_user\n" +
-		"file2.txt This is file 2. " + time.Now().Format("2006-01-02 15:04:05") + " test_folder test_user\n" +
-		"file3.txt This is file 3. " + time.Now().Format("2006-01-02 15:04:05") + " test_folder test_user"
+	expected := "file1.txt This is file 1. 2023-05-01 10:30:00 test_folder test_user\n" +
+		"file2.txt This is file 2. 2023-05-01 10:31:00 test_folder test_user\n" +
+		"file3.txt This is file 3. 2023-05-01 10:32:00 test_folder test_user"
 	if result != expected {
 		t.Errorf("Expected '%s' but got '%s'", expected, result)
 	}
@@ -186,3 +249,145 @@ func TestFolder_isFileExists(t *testing.T) {
 		})
 	}
 }
+
+func TestListFilesSortModified(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	for _, filename := range []string{"file1.txt", "file2.txt"} {
+		if err := fs.CreateFile("test_user", "test_folder", filename, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+
+	// Pretend both files were created a day ago
+	past := time.Now().Add(-24 * time.Hour)
+	folder := fs.getUserByUsername("test_user").Folders["test_folder"]
+	for _, file := range folder.Files {
+		folder.fileIndex.update(file, func() {
+			file.CreatedAt, file.ModifiedAt, file.AccessedAt = past, past, past
+		})
+	}
+
+	// Writing modifies file1, reading only accesses file2
+	if err := fs.WriteFile("test_user", "test_folder", "file1.txt", []byte("hello")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if _, err := fs.ReadFile("test_user", "test_folder", "file2.txt"); err != nil {
+		t.Fatalf("Failed to read file: %s", err)
+	}
+	if !folder.Files["file2.txt"].ModifiedAt.Equal(past) || folder.Files["file2.txt"].AccessedAt.Equal(past) {
+		t.Errorf("Expected reading to update AccessedAt only")
+	}
+
+	result, err := fs.ListFiles("test_user", "test_folder", "--sort-modified", "desc")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expected := "file1.txt  " + past.Format("2006-01-02 15:04:05") + " test_folder test_user\n" +
+		"file2.txt  " + past.Format("2006-01-02 15:04:05") + " test_folder test_user"
+	if result != expected {
+		t.Errorf("Expected '%s' but got '%s'", expected, result)
+	}
+
+	result, err = fs.ListFiles("test_user", "test_folder", "--sort-modified", "asc")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if !strings.HasPrefix(result, "file2.txt") {
+		t.Errorf("Expected file2.txt first but got '%s'", result)
+	}
+}
+
+func TestSetFileDescription(t *testing.T) {
+	fs := NewFileSystem()
+
+	// Create a user, a folder, and a file
+	err := fs.Register("test_user")
+	if err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	err = fs.CreateFolder("test_user", "test_folder", "test_description")
+	if err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	err = fs.CreateFile("test_user", "test_folder", "test_file.txt", "This is a test file.")
+	if err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	w, err := fs.Watch("test_user", "test_folder")
+	if err != nil {
+		t.Fatalf("Failed to watch folder: %s", err)
+	}
+	defer w.Close()
+
+	// Set a new description
+	err = fs.SetFileDescription("test_user", "test_folder", "test_file.txt", "This is a fixed test file.")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	file := fs.getUserByUsername("test_user").Folders["test_folder"].Files["test_file.txt"]
+	if file.Description != "This is a fixed test file." {
+		t.Errorf("Expected the new description but got '%s'", file.Description)
+	}
+	if got := <-w.Events; got.Type != EventDescriptionChanged || got.File != "test_file.txt" {
+		t.Errorf("Expected a description-changed event but got %+v", got)
+	}
+
+	// Clear the description
+	err = fs.SetFileDescription("test_user", "test_folder", "test_file.txt", "")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	if file.Description != "" {
+		t.Errorf("Expected an empty description but got '%s'", file.Description)
+	}
+
+	// Try to set the description of a non-existent file
+	err = fs.SetFileDescription("test_user", "test_folder", "non_existent_file.txt", "description")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Try to set the description of a file in a non-existent folder
+	err = fs.SetFileDescription("test_user", "non_existent_folder", "test_file.txt", "description")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	// Reads share the read lock while updating the access times, run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := fs.ReadFile("test_user", "test_folder", "test_file.txt"); err != nil {
+					t.Errorf("Failed to read file: %s", err)
+				}
+				if _, err := fs.ListFiles("test_user", "test_folder", "", ""); err != nil {
+					t.Errorf("Failed to list files: %s", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"fmt"
+	"iscool/vfs/controller/validate"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FindQuery selects folders and files, zero fields match everything
+type FindQuery struct {
+	// Name is a glob pattern using the syntax of path.Match
+	Name string
+	// Description is a regular expression searched in the description
+	Description   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Type is "folder", "file" or empty for both
+	Type string
+}
+
+// found is a folder or a file matched by Find
+type found struct {
+	user   *User
+	folder *Folder
+	file   *File
+}
+
+func (f found) name() string {
+	if f.file != nil {
+		return f.file.Name
+	}
+	return f.folder.Name
+}
+
+func (f found) createdAt() time.Time {
+	if f.file != nil {
+		return f.file.CreatedAt
+	}
+	return f.folder.CreatedAt
+}
+
+func (f found) modifiedAt() time.Time {
+	if f.file != nil {
+		return f.file.ModifiedAt
+	}
+	return f.folder.ModifiedAt
+}
+
+func (f found) path() string {
+	return targetPath(f.user.Name, f.folder.Name, f.name())
+}
+
+// Find searches every folder of the user, or of every user when username is empty,
+// and lists the matches in the same format as ListFolders and ListFiles
+func (fs *FileSystem) Find(username string, query FindQuery, sortBy string, sortOrder string) (listing string, err error) {
+	call := Call{Operation: "find", User: username}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return "", err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return "", err
+	}
+	users := make([]*User, 0, len(fs.Users))
+	if username == "" {
+		for _, user := range fs.Users {
+			users = append(users, user)
+		}
+	} else {
+		user := fs.getUserByUsername(username)
+		if user == nil {
+			return "", newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+		}
+		users = append(users, user)
+	}
+
+	if err := validateSort(sortBy, sortOrder); err != nil {
+		return "", err
+	}
+
+	if query.Type != "" && query.Type != "folder" && query.Type != "file" {
+		return "", fmt.Errorf("Error: Unknown type %s. Valid types are 'file' 'folder'", query.Type)
+	}
+	query.Name = validate.Normalize(query.Name)
+	if _, err := path.Match(query.Name, ""); err != nil {
+		return "", fmt.Errorf("Error: Invalid name pattern %s.", query.Name)
+	}
+	var description *regexp.Regexp
+	if query.Description != "" {
+		var err error
+		description, err = regexp.Compile(query.Description)
+		if err != nil {
+			return "", fmt.Errorf("Error: Invalid description pattern %s.", query.Description)
+		}
+	}
+
+	// Names are matched like the glob patterns of the other commands, following the case mode
+	match := func(mode CaseMode, name, desc string, createdAt time.Time) bool {
+		if query.Name != "" && !mode.match(query.Name, name) {
+			return false
+		}
+		if description != nil && !description.MatchString(desc) {
+			return false
+		}
+		if !query.CreatedAfter.IsZero() && !createdAt.After(query.CreatedAfter) {
+			return false
+		}
+		if !query.CreatedBefore.IsZero() && !createdAt.Before(query.CreatedBefore) {
+			return false
+		}
+		return true
+	}
+
+	var results []found
+	for _, user := range users {
+		for _, folder := range user.Folders {
+			if query.Type != "file" && match(user.caseMode, folder.Name, folder.Description, folder.CreatedAt) {
+				results = append(results, found{user: user, folder: folder})
+			}
+			if query.Type == "folder" {
+				continue
+			}
+			for _, file := range folder.Files {
+				if match(folder.caseMode, file.Name, file.Description, file.CreatedAt) {
+					results = append(results, found{user: user, folder: folder, file: file})
+				}
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		return "", newError(ErrWarning, "Warning: No folders or files match.")
+	}
+
+	// Order by path first so that entries with equal sort keys are listed deterministically
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].path() < results[j].path()
+	})
+	switch sortBy {
+	case "--sort-created":
+		sort.SliceStable(results, func(i, j int) bool {
+			if sortOrder == "asc" {
+				return results[i].createdAt().Before(results[j].createdAt())
+			}
+			return results[i].createdAt().After(results[j].createdAt())
+		})
+	case "--sort-modified":
+		sort.SliceStable(results, func(i, j int) bool {
+			if sortOrder == "asc" {
+				return results[i].modifiedAt().Before(results[j].modifiedAt())
+			}
+			return results[i].modifiedAt().After(results[j].modifiedAt())
+		})
+	default:
+		// sort by name asc by default
+		sort.SliceStable(results, func(i, j int) bool {
+			if sortOrder == "desc" {
+				return results[i].name() > results[j].name()
+			}
+			return results[i].name() < results[j].name()
+		})
+	}
+
+	var output []string
+	for _, result := range results {
+		if result.file == nil {
+			output = append(output, fmt.Sprintf("%s %s %s %s", result.folder.Name, result.folder.Description, fs.formatTime(result.folder.CreatedAt), result.user.Name))
+			continue
+		}
+		output = append(output, fmt.Sprintf("%s %s %s %s %s", result.file.Name, result.file.Description, fs.formatTime(result.file.CreatedAt), result.folder.Name, result.user.Name))
+	}
+	return strings.Join(output, "\n"), nil
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	fs := NewFileSystem()
+	for _, username := range []string{"test_user", "test_user2"} {
+		if err := fs.Register(username); err != nil {
+			t.Fatalf("Failed to register user: %s", err)
+		}
+	}
+	if err := fs.CreateFolder("test_user", "invoices", "paid invoices"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "photos", "holiday"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "invoices", "2023-01.pdf", "invoice january"); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "invoices", "2023-02.pdf", "invoice february"); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "photos", "beach.jpg", "holiday"); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.CreateFolder("test_user2", "invoices", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	// Spread the creation times over three days
+	day := time.Date(2023, 3, 1, 0, 0, 0, 0, time.Local)
+	user := fs.getUserByUsername("test_user")
+	user.Folders["invoices"].CreatedAt = day
+	user.Folders["photos"].CreatedAt = day
+	user.Folders["invoices"].Files["2023-01.pdf"].CreatedAt = day
+	user.Folders["invoices"].Files["2023-02.pdf"].CreatedAt = day.Add(24 * time.Hour)
+	user.Folders["photos"].Files["beach.jpg"].CreatedAt = day.Add(48 * time.Hour)
+	fs.getUserByUsername("test_user2").Folders["invoices"].CreatedAt = day
+	date := day.Format("2006-01-02 15:04:05")
+
+	tests := []struct {
+		name      string
+		username  string
+		query     FindQuery
+		sortBy    string
+		sortOrder string
+		expected  string
+		wantErr   bool
+	}{
+		{
+			name:     "by name",
+			username: "test_user",
+			query:    FindQuery{Name: "*.pdf"},
+			expected: "2023-01.pdf invoice january " + date + " invoices test_user\n" +
+				"2023-02.pdf invoice february " + day.Add(24*time.Hour).Format("2006-01-02 15:04:05") + " invoices test_user",
+		},
+		{
+			name:     "by description and type",
+			username: "test_user",
+			query:    FindQuery{Description: "^holi", Type: "folder"},
+			expected: "photos holiday " + date + " test_user",
+		},
+		{
+			name:      "by creation time sorted",
+			username:  "test_user",
+			query:     FindQuery{CreatedAfter: day, CreatedBefore: day.Add(72 * time.Hour)},
+			sortBy:    "--sort-created",
+			sortOrder: "desc",
+			expected: "beach.jpg holiday " + day.Add(48*time.Hour).Format("2006-01-02 15:04:05") + " photos test_user\n" +
+				"2023-02.pdf invoice february " + day.Add(24*time.Hour).Format("2006-01-02 15:04:05") + " invoices test_user",
+		},
+		{
+			name:     "across users",
+			query:    FindQuery{Name: "invoices"},
+			expected: "invoices paid invoices " + date + " test_user\ninvoices  " + date + " test_user2",
+		},
+		{
+			name:     "no match",
+			username: "test_user",
+			query:    FindQuery{Name: "*.doc"},
+			wantErr:  true,
+		},
+		{
+			name:     "non-existent user",
+			username: "non_existent_user",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid type",
+			username: "test_user",
+			query:    FindQuery{Type: "link"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid description pattern",
+			username: "test_user",
+			query:    FindQuery{Description: "("},
+			wantErr:  true,
+		},
+		{
+			name:      "invalid sort order",
+			username:  "test_user",
+			sortBy:    "--sort-name",
+			sortOrder: "invalid_sort_order",
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := fs.Find(test.username, test.query, test.sortBy, test.sortOrder)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expected error %v but got '%v'", test.wantErr, err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected '%s' but got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestFindCaseMode(t *testing.T) {
+	fs := NewFileSystem(WithCaseMode(CasePreserving))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "docs", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "docs", "Notes.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	// The name pattern ignores case like the globs do
+	output, err := fs.Find("test_user", FindQuery{Name: "Docs*"}, "", "")
+	if err != nil || !strings.Contains(output, "docs") {
+		t.Errorf("Expected docs to be found but got '%s' (%v)", output, err)
+	}
+	output, err = fs.Find("test_user", FindQuery{Name: "notes.*", Type: "file"}, "", "")
+	if err != nil || !strings.Contains(output, "Notes.txt") {
+		t.Errorf("Expected Notes.txt to be found but got '%s' (%v)", output, err)
+	}
+}
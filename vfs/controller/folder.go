@@ -3,151 +3,310 @@ package controller
 import (
 	"fmt"
 	"iscool/vfs/controller/validate"
-	"sort"
 	"strings"
-	"time"
 )
 
 // CreateFolder creates a new folder for the user
-func (fs *FileSystem) CreateFolder(username string, foldername string, description string) error {
+func (fs *FileSystem) CreateFolder(username string, foldername string, description string) (err error) {
+	call := Call{Operation: "create-folder", User: username, Folder: foldername, Args: map[string]string{"description": description}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("create-folder", username, username+"/"+foldername, call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
 	user := fs.getUserByUsername(username)
 	if user == nil {
-		return fmt.Errorf("Error: %s does not exist.", username)
+		return newError(ErrNotFound, "Error: %s does not exist.", username)
 	}
 
-	if validate.ValidateNoInvalidChars(foldername) {
-		return fmt.Errorf("Error: The %s contain invalid chars.", foldername)
+	foldername = user.caseMode.name(validate.Normalize(foldername))
+	if err := fs.validateName(entityFolder, foldername); err != nil {
+		return err
 	}
 
-	if validate.ValidateLength(foldername, 100) {
-		return fmt.Errorf("Error: Foldername be under %d characters.", 100)
+	if user.isFolderExists(foldername) {
+		return newError(ErrAlreadyExists, "Error: The %s has already existed.", foldername)
 	}
 
-	if user.isFolderExists(foldername) {
-		return fmt.Errorf("Error: The %s has already existed.", foldername)
+	if err := fs.checkQuota(user, 1, 0, 0); err != nil {
+		return err
 	}
 
+	now := fs.clock.Now()
 	folder := &Folder{
 		Name:        foldername,
 		Description: description,
-		CreatedAt:   time.Now(),
+		CreatedAt:   now,
+		ModifiedAt:  now,
+		AccessedAt:  now,
 		Files:       make(map[string]*File),
+		caseMode:    user.caseMode,
 	}
 
-	user.Folders[foldername] = folder
+	fs.addFolder(user, folder)
+	fs.recordChange("create-folder "+username+"/"+foldername, func() bool {
+		// Files added since would be lost
+		if !fs.hasFolder(user, folder) || len(folder.Files) > 0 {
+			return false
+		}
+		fs.removeFolder(user, folder)
+		return true
+	}, func() bool {
+		if !fs.hasUser(user) || user.isFolderExists(folder.Name) || !fs.withinQuota(user, 1, 0, 0) {
+			return false
+		}
+		fs.addFolder(user, folder)
+		return true
+	})
 
 	return nil
 }
 
 // ListFolders lists all the folders for the user
-func (fs *FileSystem) ListFolders(username string, sortBy string, sortOrder string) (string, error) {
+func (fs *FileSystem) ListFolders(username string, sortBy string, sortOrder string, opts ...ListOption) (string, error) {
+	output, _, err := fs.ListFoldersPage(username, sortBy, sortOrder, opts...)
+	return output, err
+}
+
+// ListFoldersPage lists the folders for the user like ListFolders and also returns
+// the cursor of the next page, which is empty on the last page
+func (fs *FileSystem) ListFoldersPage(username string, sortBy string, sortOrder string, opts ...ListOption) (listing, next string, err error) {
+	call := Call{Operation: "list-folders", User: username}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return "", "", err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return "", "", err
+	}
+	options := newListOptions(opts)
+
 	user := fs.getUserByUsername(username)
 	if user == nil {
-		return "", fmt.Errorf("Error: %s doesn't exist.", username)
+		return "", "", newError(ErrNotFound, "Error: %s doesn't exist.", username)
 	}
 
-	switch sortBy + " " + sortOrder {
-	case "--sort-name asc", "--sort-name desc", "--sort-created asc", "--sort-created desc", " ":
-	default:
+	if err := validateSort(sortBy, sortOrder); err != nil {
 		// suggest a valid flag to the user
-		return "", fmt.Errorf("Error: Unknown flag. Valid flags are '--sort-name asc' '--sort-name asc' '--sort-created asc' '--sort-created desc'")
+		return "", "", err
 	}
 
-	folders := user.Folders
-
-	if len(folders) == 0 {
-		return "", fmt.Errorf("Warning: The %s doesn't have any folders.", username)
+	if len(user.Folders) == 0 {
+		return "", "", newError(ErrWarning, "Warning: The %s doesn't have any folders.", username)
 	}
 
-	// Create a slice to store the folder information
-	folderInfo := make([]*Folder, 0, len(folders))
-
-	// Append the folder information to the slice
-	for _, folder := range folders {
-		folderInfo = append(folderInfo, folder)
+	// Walk the folders in the selected sorting order and keep the requested page
+	folderInfo, next, err := page(&user.folderIndex, sortBy, sortOrder, options)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Sort the folderInfo based on the selected sorting option
-	switch sortBy {
-	case "--sort-name":
-		sort.Slice(folderInfo, func(i, j int) bool {
-			if sortOrder == "asc" {
-				return folderInfo[i].Name < folderInfo[j].Name
-			}
-			return folderInfo[i].Name > folderInfo[j].Name
-		})
-	case "--sort-created":
-		sort.Slice(folderInfo, func(i, j int) bool {
-			if sortOrder == "asc" {
-				return folderInfo[i].CreatedAt.Before(folderInfo[j].CreatedAt)
-			}
-			return folderInfo[i].CreatedAt.After(folderInfo[j].CreatedAt)
-		})
-	default:
-		// Sort by folder name in ascending order by default
-		sort.Slice(folderInfo, func(i, j int) bool {
-			return folderInfo[i].Name < folderInfo[j].Name
-		})
+	if len(folderInfo) == 0 {
+		return "", "", newError(ErrWarning, "Warning: No more folders to list.")
 	}
 
 	var output []string
 	// Print the folder information in the specified format
 	for _, folder := range folderInfo {
-		output = append(output, fmt.Sprintf("%s %s %s %s", folder.Name, folder.Description, folder.CreatedAt.Format("2006-01-02 15:04:05"), username))
+		output = append(output, fmt.Sprintf("%s %s %s %s", folder.Name, folder.Description, fs.formatTime(folder.CreatedAt), username))
 	}
 	result := strings.Join(output, "\n")
-	return result, nil
+	return result, next, nil
 }
 
 // DeleteFolder deletes the specified folder for the user
-func (fs *FileSystem) DeleteFolder(username string, foldername string) error {
+func (fs *FileSystem) DeleteFolder(username string, foldername string) (err error) {
+	call := Call{Operation: "delete-folder", User: username, Folder: foldername}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("delete-folder", username, username+"/"+foldername, nil, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
 	user := fs.getUserByUsername(username)
 	if user == nil {
-		return fmt.Errorf("Error: %s doesn't exist.", username)
+		return newError(ErrNotFound, "Error: %s doesn't exist.", username)
 	}
-	folder := user.Folders[foldername]
+	folder := user.getFolderByName(foldername)
 	if folder == nil {
-		return fmt.Errorf("Error: %s doesn't exist.", foldername)
+		return newError(ErrNotFound, "Error: %s doesn't exist.", foldername)
 	}
-	delete(user.Folders, foldername)
+	fs.removeFolder(user, folder)
+	fs.recordChange("delete-folder "+username+"/"+foldername, func() bool {
+		if !fs.hasUser(user) || user.isFolderExists(folder.Name) || !fs.withinQuota(user, 1, len(folder.Files), folderBytes(folder)) {
+			return false
+		}
+		fs.addFolder(user, folder)
+		return true
+	}, func() bool {
+		if !fs.hasFolder(user, folder) {
+			return false
+		}
+		fs.removeFolder(user, folder)
+		return true
+	})
 	return nil
 }
 
 // RenameFolder renames the specified folder for the user
-func (fs *FileSystem) RenameFolder(username string, foldername string, newFolderName string) error {
+func (fs *FileSystem) RenameFolder(username string, foldername string, newFolderName string) (err error) {
+	call := Call{Operation: "rename-folder", User: username, Folder: foldername, Args: map[string]string{"new_name": newFolderName}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("rename-folder", username, username+"/"+foldername, call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
 	user := fs.getUserByUsername(username)
 	if user == nil {
-		return fmt.Errorf("Error: %s doesn't exist.", username)
+		return newError(ErrNotFound, "Error: %s doesn't exist.", username)
 	}
 	folder := user.getFolderByName(foldername)
 	if folder == nil {
-		return fmt.Errorf("Error: %s doesn't exist.", foldername)
+		return newError(ErrNotFound, "Error: %s doesn't exist.", foldername)
 	}
 
-	if folder.Name == newFolderName {
+	oldName := folder.Name
+	newFolderName = user.caseMode.name(validate.Normalize(newFolderName))
+	if oldName == newFolderName {
 		return nil // No need to rename if the new folder name is the same
 	}
 
-	if validate.ValidateNoInvalidChars(newFolderName) {
-		return fmt.Errorf("Error: The %s contain invalid chars.", newFolderName)
+	if err := fs.validateName(entityFolder, newFolderName); err != nil {
+		return err
+	}
+
+	// Changing only the case of a name finds the folder itself when case is ignored
+	taken := func(name string) bool {
+		other := user.getFolderByName(name)
+		return other != nil && other != folder
+	}
+	if taken(newFolderName) {
+		return newError(ErrAlreadyExists, "Error: The %s has already existed.", newFolderName)
+	}
+	fs.renameFolder(user, folder, newFolderName)
+	fs.recordChange("rename-folder "+username+"/"+foldername, func() bool {
+		if !fs.hasFolder(user, folder) || folder.Name != newFolderName || taken(oldName) {
+			return false
+		}
+		fs.renameFolder(user, folder, oldName)
+		return true
+	}, func() bool {
+		if !fs.hasFolder(user, folder) || folder.Name != oldName || taken(newFolderName) {
+			return false
+		}
+		fs.renameFolder(user, folder, newFolderName)
+		return true
+	})
+	return nil
+}
+
+// SetFolderDescription replaces the description of the specified folder, an empty description clears it
+func (fs *FileSystem) SetFolderDescription(username string, foldername string, description string) (err error) {
+	call := Call{Operation: "set-folder-description", User: username, Folder: foldername, Args: map[string]string{"description": description}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("set-folder-description", username, username+"/"+foldername, call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
 	}
 
-	if validate.ValidateLength(newFolderName, 255) {
-		return fmt.Errorf("Error: The Filename be under %d characters.", 255)
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return newError(ErrNotFound, "Error: %s doesn't exist.", username)
+	}
+	folder := user.getFolderByName(foldername)
+	if folder == nil {
+		return newError(ErrNotFound, "Error: %s doesn't exist.", foldername)
 	}
 
-	if user.isFolderExists(newFolderName) {
-		return fmt.Errorf("Error: The %s has already existed.", newFolderName)
+	if folder.Description == description {
+		return nil
 	}
-	user.Folders[newFolderName] = folder
-	folder.Name = newFolderName
-	delete(user.Folders, foldername)
+	previous := folder.Description
+	fs.setFolderDescription(user, folder, description)
+	fs.recordChange("set-folder-description "+username+"/"+foldername, func() bool {
+		if !fs.hasFolder(user, folder) || folder.Description != description {
+			return false
+		}
+		fs.setFolderDescription(user, folder, previous)
+		return true
+	}, func() bool {
+		if !fs.hasFolder(user, folder) || folder.Description != previous {
+			return false
+		}
+		fs.setFolderDescription(user, folder, description)
+		return true
+	})
 	return nil
 }
 
+// addFolder attaches a new or previously removed folder to the user
+func (fs *FileSystem) addFolder(user *User, folder *Folder) {
+	user.Folders[user.caseMode.key(folder.Name)] = folder
+	user.folderIndex.insert(folder)
+	fs.adjustUsage(user, 1, len(folder.Files), folderBytes(folder))
+	fs.indexFolder(user, folder)
+	for _, file := range folder.Files {
+		fs.indexFile(user, folder, file)
+	}
+	fs.notify(user, folder, Event{Type: EventCreated, Folder: folder.Name})
+}
+
+// removeFolder detaches the folder and its files from the user
+func (fs *FileSystem) removeFolder(user *User, folder *Folder) {
+	fs.unindexFolder(user, folder)
+	delete(user.Folders, user.caseMode.key(folder.Name))
+	user.folderIndex.remove(folder)
+	fs.adjustUsage(user, -1, -len(folder.Files), -folderBytes(folder))
+	fs.notify(user, folder, Event{Type: EventDeleted, Folder: folder.Name})
+}
+
+// renameFolder moves the folder to a free name
+func (fs *FileSystem) renameFolder(user *User, folder *Folder, newFolderName string) {
+	oldName := folder.Name
+	fs.unindexFolder(user, folder)
+	delete(user.Folders, user.caseMode.key(oldName))
+	user.Folders[user.caseMode.key(newFolderName)] = folder
+	user.folderIndex.update(folder, func() {
+		folder.Name = newFolderName
+		folder.ModifiedAt = fs.clock.Now()
+	})
+	fs.indexFolder(user, folder)
+	for _, file := range folder.Files {
+		fs.indexFile(user, folder, file)
+	}
+	fs.notify(user, folder, Event{Type: EventRenamed, Folder: newFolderName, OldName: oldName})
+}
+
+// setFolderDescription replaces the description of the folder
+func (fs *FileSystem) setFolderDescription(user *User, folder *Folder, description string) {
+	folder.Description = description
+	user.folderIndex.update(folder, func() { folder.ModifiedAt = fs.clock.Now() })
+	fs.indexFolder(user, folder)
+	fs.notify(user, folder, Event{Type: EventDescriptionChanged, Folder: folder.Name})
+}
+
 // getUserByUsername returns the specified user
 func (fs *FileSystem) getUserByUsername(name string) *User {
-	user, ok := fs.Users[strings.ToLower(name)]
+	user, ok := fs.Users[userKey(name)]
 	if ok {
 		return user
 	}
@@ -156,7 +315,7 @@ func (fs *FileSystem) getUserByUsername(name string) *User {
 
 // getFolderByName returns the specified folder for the user
 func (u *User) getFolderByName(foldername string) *Folder {
-	folder, ok := u.Folders[foldername]
+	folder, ok := u.Folders[u.caseMode.key(foldername)]
 	if ok {
 		return folder
 	}
@@ -165,6 +324,6 @@ func (u *User) getFolderByName(foldername string) *Folder {
 
 // isFolderExists checks if the specified folder exists for the user
 func (u *User) isFolderExists(foldername string) bool {
-	_, exists := u.Folders[foldername]
+	_, exists := u.Folders[u.caseMode.key(foldername)]
 	return exists
 }
@@ -46,7 +46,7 @@ func TestCreateFolder(t *testing.T) {
 }
 
 func TestListFolders(t *testing.T) {
-	fs := NewFileSystem()
+	fs := NewFileSystem(WithClock(NewFakeClock(time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC))))
 
 	// Test listing folders for a user that doesn't exist
 	_, err := fs.ListFolders("test_user", "--sort-name", "asc")
@@ -86,7 +86,7 @@ func TestListFolders(t *testing.T) {
 	if err != nil {
 		t.Errorf("Expected no error but got '%s'", err.Error())
 	}
-	expectedResult := "folder1 description1 " + time.Now().Format("2006-01-02 15:04:05") + " test_user\nfolder2 description2 " + time.Now().Format("2006-01-02 15:04:05") + " test_user"
+	expectedResult := "folder1 description1 2023-05-01 10:30:00 test_user\nfolder2 description2 2023-05-01 10:30:00 test_user"
 	if result != expectedResult {
 		t.Errorf("Expected '%s' but got '%s'", expectedResult, result)
 	}
@@ -227,3 +227,98 @@ func TestUser_isFolderExists(t *testing.T) {
 		})
 	}
 }
+
+func TestListFoldersSortModified(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, foldername := range []string{"folder1", "folder2"} {
+		if err := fs.CreateFolder("test_user", foldername, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+
+	// Pretend both folders were created a day ago
+	past := time.Now().Add(-24 * time.Hour)
+	user := fs.getUserByUsername("test_user")
+	for _, folder := range user.Folders {
+		user.folderIndex.update(folder, func() {
+			folder.CreatedAt, folder.ModifiedAt = past, past
+		})
+	}
+
+	// Adding a file modifies folder1, renaming modifies folder2
+	if err := fs.CreateFile("test_user", "folder1", "file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if user.Folders["folder1"].ModifiedAt.Equal(past) {
+		t.Errorf("Expected creating a file to update ModifiedAt")
+	}
+	folder1 := user.Folders["folder1"]
+	user.folderIndex.update(folder1, func() { folder1.ModifiedAt = past.Add(time.Hour) })
+	if err := fs.RenameFolder("test_user", "folder2", "folder3"); err != nil {
+		t.Fatalf("Failed to rename folder: %s", err)
+	}
+	if !user.Folders["folder3"].CreatedAt.Equal(past) {
+		t.Errorf("Expected renaming not to change CreatedAt")
+	}
+
+	result, err := fs.ListFolders("test_user", "--sort-modified", "desc")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectedResult := "folder3  " + past.Format("2006-01-02 15:04:05") + " test_user\nfolder1  " + past.Format("2006-01-02 15:04:05") + " test_user"
+	if result != expectedResult {
+		t.Errorf("Expected '%s' but got '%s'", expectedResult, result)
+	}
+
+	_, err = fs.ListFolders("test_user", "--sort-modified", "invalid_sort_order")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestSetFolderDescription(t *testing.T) {
+	fs := NewFileSystem()
+
+	// Test setting the description for a user that doesn't exist
+	err := fs.SetFolderDescription("test_user", "test_folder", "new description")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test setting the description of a folder that doesn't exist
+	err = fs.Register("test_user")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	err = fs.SetFolderDescription("test_user", "test_folder", "new description")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test setting a multi-word description keeps CreatedAt
+	err = fs.CreateFolder("test_user", "test_folder", "test_description")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	folder := fs.getUserByUsername("test_user").Folders["test_folder"]
+	createdAt := folder.CreatedAt
+	err = fs.SetFolderDescription("test_user", "test_folder", "new description")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	if folder.Description != "new description" || !folder.CreatedAt.Equal(createdAt) {
+		t.Errorf("Expected the description to change and CreatedAt to stay but got %+v", folder)
+	}
+
+	// Test clearing the description
+	err = fs.SetFolderDescription("test_user", "test_folder", "")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	if folder.Description != "" {
+		t.Errorf("Expected an empty description but got '%s'", folder.Description)
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"fmt"
+	"iscool/vfs/controller/validate"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FileMatch is a file matched by GlobFiles
+type FileMatch struct {
+	Folder string
+	File   string
+}
+
+// HasGlob reports whether the name contains glob metacharacters.
+// '*' and '?' are invalid in names, but '[' is not, so the glob functions
+// take a pattern naming an existing folder or file as that name.
+func HasGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// GlobFolders returns the sorted names of the user's folders matching the pattern,
+// which uses the syntax of path.Match. A pattern that is the name of a folder only
+// matches that folder.
+func (fs *FileSystem) GlobFolders(username string, pattern string) (names []string, err error) {
+	call := Call{Operation: "glob-folders", User: username}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return nil, err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return nil, err
+	}
+	return fs.globFolders(username, pattern)
+}
+
+// globFolders is GlobFolders without the lock
+func (fs *FileSystem) globFolders(username string, pattern string) ([]string, error) {
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil, newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+	pattern = validate.Normalize(pattern)
+	if folder := user.getFolderByName(pattern); folder != nil {
+		return []string{folder.Name}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Error: Invalid pattern %s.", pattern)
+	}
+
+	var names []string
+	for _, folder := range user.Folders {
+		if user.caseMode.match(pattern, folder.Name) {
+			names = append(names, folder.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GlobFiles returns the files matching filePattern in the folders matching folderPattern,
+// sorted by folder then file name. In a folder holding a file named filePattern only
+// that file matches.
+func (fs *FileSystem) GlobFiles(username string, folderPattern string, filePattern string) (matches []FileMatch, err error) {
+	call := Call{Operation: "glob-files", User: username}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return nil, err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return nil, err
+	}
+	folders, err := fs.globFolders(username, folderPattern)
+	if err != nil {
+		return nil, err
+	}
+	filePattern = validate.Normalize(filePattern)
+	_, patternErr := path.Match(filePattern, "")
+	if patternErr != nil && len(folders) == 0 {
+		return nil, fmt.Errorf("Error: Invalid pattern %s.", filePattern)
+	}
+
+	user := fs.getUserByUsername(username)
+	for _, foldername := range folders {
+		folder := user.getFolderByName(foldername)
+		var names []string
+		if file := folder.getFileByName(filePattern); file != nil {
+			names = append(names, file.Name)
+		} else if patternErr != nil {
+			return nil, fmt.Errorf("Error: Invalid pattern %s.", filePattern)
+		} else {
+			for _, file := range folder.Files {
+				if folder.caseMode.match(filePattern, file.Name) {
+					names = append(names, file.Name)
+				}
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			matches = append(matches, FileMatch{Folder: foldername, File: name})
+		}
+	}
+	return matches, nil
+}
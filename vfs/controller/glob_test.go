@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasGlob(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{name: "plain name", input: "report.txt", expected: false},
+		{name: "star", input: "*.tmp", expected: true},
+		{name: "question mark", input: "file?.txt", expected: true},
+		{name: "character class", input: "file[0-9].txt", expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := HasGlob(test.input); result != test.expected {
+				t.Errorf("Expected %v but got %v for input %s", test.expected, result, test.input)
+			}
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	files := map[string][]string{
+		"docs":   {"a.tmp", "b.tmp", "c.txt"},
+		"drafts": {"d.tmp"},
+		"photos": {"e.jpg"},
+	}
+	for foldername, filenames := range files {
+		if err := fs.CreateFolder("test_user", foldername, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+		for _, filename := range filenames {
+			if err := fs.CreateFile("test_user", foldername, filename, ""); err != nil {
+				t.Fatalf("Failed to create file: %s", err)
+			}
+		}
+	}
+
+	folders, err := fs.GlobFolders("test_user", "d*")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if want := []string{"docs", "drafts"}; !reflect.DeepEqual(folders, want) {
+		t.Errorf("Expected %v but got %v", want, folders)
+	}
+
+	matches, err := fs.GlobFiles("test_user", "d*", "*.tmp")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	want := []FileMatch{{"docs", "a.tmp"}, {"docs", "b.tmp"}, {"drafts", "d.tmp"}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("Expected %v but got %v", want, matches)
+	}
+
+	// A pattern without metacharacters matches the name itself
+	matches, err = fs.GlobFiles("test_user", "photos", "e.jpg")
+	if err != nil || len(matches) != 1 {
+		t.Errorf("Expected one match but got %v (%v)", matches, err)
+	}
+
+	// Test matching nothing
+	matches, err = fs.GlobFiles("test_user", "*", "*.pdf")
+	if err != nil || len(matches) != 0 {
+		t.Errorf("Expected no match but got %v (%v)", matches, err)
+	}
+
+	// Test an invalid pattern
+	if _, err := fs.GlobFiles("test_user", "docs", "[a-"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test a user that doesn't exist
+	if _, err := fs.GlobFolders("non_existent_user", "*"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestGlobExactName(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, foldername := range []string{"docs[1]", "docs1"} {
+		if err := fs.CreateFolder("test_user", foldername, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+	for _, filename := range []string{"report[1].txt", "report1.txt", "a[b"} {
+		if err := fs.CreateFile("test_user", "docs1", filename, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+
+	// An existing name is taken literally rather than as a pattern
+	folders, err := fs.GlobFolders("test_user", "docs[1]")
+	if want := []string{"docs[1]"}; err != nil || !reflect.DeepEqual(folders, want) {
+		t.Errorf("Expected %v but got %v (%v)", want, folders, err)
+	}
+	matches, err := fs.GlobFiles("test_user", "docs1", "report[1].txt")
+	if want := []FileMatch{{"docs1", "report[1].txt"}}; err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("Expected %v but got %v (%v)", want, matches, err)
+	}
+
+	// A name that is not a valid pattern still finds the file
+	matches, err = fs.GlobFiles("test_user", "docs1", "a[b")
+	if want := []FileMatch{{"docs1", "a[b"}}; err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("Expected %v but got %v (%v)", want, matches, err)
+	}
+
+	// Without an exact match the name is a pattern
+	matches, err = fs.GlobFiles("test_user", "docs1", "report[2-9].txt")
+	if err != nil || len(matches) != 0 {
+		t.Errorf("Expected no match but got %v (%v)", matches, err)
+	}
+	matches, err = fs.GlobFiles("test_user", "docs1", "report[0-9].txt")
+	if want := []FileMatch{{"docs1", "report1.txt"}}; err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("Expected %v but got %v (%v)", want, matches, err)
+	}
+}
@@ -0,0 +1,138 @@
+package controller
+
+// DefaultHistoryLimit is the number of changes NewHistory keeps
+const DefaultHistoryLimit = 100
+
+// History keeps the changes made through a FileSystem so that they can be undone and redone.
+// Changes hold on to what they replaced, such as deleted folders and previous contents,
+// so only the latest ones are kept.
+type History struct {
+	limit int
+	undo  []*change
+	redo  []*change
+}
+
+// change is a successful mutating call along with the functions reverting and replaying it.
+// Each function reports false, leaving the state untouched, when the state has changed
+// in a way that conflicts with it since.
+type change struct {
+	action string
+	undo   func() bool
+	redo   func() bool
+}
+
+// NewHistory returns an empty history keeping the latest DefaultHistoryLimit changes
+func NewHistory() *History {
+	return NewHistoryLimit(DefaultHistoryLimit)
+}
+
+// NewHistoryLimit returns an empty history keeping the latest limit changes, the older
+// ones can no longer be undone
+func NewHistoryLimit(limit int) *History {
+	return &History{limit: limit}
+}
+
+// WithHistory records every successful mutating call to the history
+func WithHistory(history *History) Option {
+	return func(fs *FileSystem) {
+		fs.history = history
+	}
+}
+
+// recordChange pushes a change on the undo stack, a new change can no longer be redone after
+func (fs *FileSystem) recordChange(action string, undo, redo func() bool) {
+	if fs.tx != nil {
+		fs.tx.changes = append(fs.tx.changes, &change{action: action, undo: undo, redo: redo})
+		return
+	}
+	if fs.history == nil {
+		return
+	}
+	fs.history.undo = append(fs.history.undo, &change{action: action, undo: undo, redo: redo})
+	fs.history.redo = nil
+	if excess := len(fs.history.undo) - fs.history.limit; excess > 0 {
+		// Copying lets the dropped changes be collected
+		fs.history.undo = append([]*change(nil), fs.history.undo[excess:]...)
+	}
+}
+
+// Undo reverts the latest change that was not undone yet and returns its description.
+// A change that conflicts with the current state is dropped from the history.
+func (fs *FileSystem) Undo() (action string, err error) {
+	call := Call{Operation: "undo"}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer func() { fs.recordAudit("undo", "", action, nil, &err) }()
+
+	if fs.tx != nil {
+		return "", newError(ErrTransaction, "Error: Cannot %s inside a transaction.", "undo")
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.before(call); err != nil {
+		return "", err
+	}
+
+	if fs.history == nil || len(fs.history.undo) == 0 {
+		return "", newError(ErrWarning, "Warning: Nothing to undo.")
+	}
+
+	c := fs.history.undo[len(fs.history.undo)-1]
+	fs.history.undo = fs.history.undo[:len(fs.history.undo)-1]
+	if !c.undo() {
+		return c.action, newError(ErrConflict, "Error: Cannot undo %s, it conflicts with later changes.", c.action)
+	}
+	fs.history.redo = append(fs.history.redo, c)
+	return c.action, nil
+}
+
+// Redo replays the latest undone change and returns its description.
+// A change that conflicts with the current state is dropped from the history.
+func (fs *FileSystem) Redo() (action string, err error) {
+	call := Call{Operation: "redo"}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer func() { fs.recordAudit("redo", "", action, nil, &err) }()
+
+	if fs.tx != nil {
+		return "", newError(ErrTransaction, "Error: Cannot %s inside a transaction.", "redo")
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.before(call); err != nil {
+		return "", err
+	}
+
+	if fs.history == nil || len(fs.history.redo) == 0 {
+		return "", newError(ErrWarning, "Warning: Nothing to redo.")
+	}
+
+	c := fs.history.redo[len(fs.history.redo)-1]
+	fs.history.redo = fs.history.redo[:len(fs.history.redo)-1]
+	if !c.redo() {
+		return c.action, newError(ErrConflict, "Error: Cannot redo %s, it conflicts with later changes.", c.action)
+	}
+	fs.history.undo = append(fs.history.undo, c)
+	return c.action, nil
+}
+
+// hasUser reports whether the user is still registered
+func (fs *FileSystem) hasUser(user *User) bool {
+	return fs.Users[userKey(user.Name)] == user
+}
+
+// hasFolder reports whether the folder still belongs to the user
+func (fs *FileSystem) hasFolder(user *User, folder *Folder) bool {
+	return fs.hasUser(user) && user.getFolderByName(folder.Name) == folder
+}
+
+// hasFile reports whether the file is still in the folder of the user
+func (fs *FileSystem) hasFile(user *User, folder *Folder, file *File) bool {
+	return fs.hasFolder(user, folder) && folder.getFileByName(file.Name) == file
+}
+
+// hasTarget reports whether the folder or the file of the target still exists
+func (fs *FileSystem) hasTarget(t target) bool {
+	if t.file == nil {
+		return fs.hasFolder(t.user, t.folder)
+	}
+	return fs.hasFile(t.user, t.folder, t.file)
+}
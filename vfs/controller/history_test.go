@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	fs := NewFileSystem(WithHistory(NewHistory()))
+
+	// Test undoing without any change
+	if _, err := fs.Undo(); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", "test_description"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.WriteFile("test_user", "test_folder", "test_file.txt", []byte("hello")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := fs.RenameFolder("test_user", "test_folder", "new_folder"); err != nil {
+		t.Fatalf("Failed to rename folder: %s", err)
+	}
+	if err := fs.DeleteFolder("test_user", "new_folder"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+
+	// Undo the deletion and the rename
+	action, err := fs.Undo()
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if action != "delete-folder test_user/new_folder" {
+		t.Errorf("Expected the deletion to be undone but got '%s'", action)
+	}
+	if _, err := fs.Undo(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+
+	content, err := fs.ReadFile("test_user", "test_folder", "test_file.txt")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if string(content) != "hello" {
+		t.Errorf("Expected 'hello' but got '%s'", content)
+	}
+	usage, _, _ := fs.GetQuota("test_user")
+	if usage != (Usage{Folders: 1, Files: 1, Bytes: 5}) {
+		t.Errorf("Expected the usage to be restored but got %+v", usage)
+	}
+	if out, err := fs.Search("test_user", "hello"); err != nil || out == "" {
+		t.Errorf("Expected the file to be searchable again but got '%s', %v", out, err)
+	}
+
+	// Redo the rename
+	if _, err := fs.Redo(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if fs.getUserByUsername("test_user").getFolderByName("new_folder") == nil {
+		t.Errorf("Expected the folder to be renamed again")
+	}
+
+	// A new change clears the redo stack
+	if err := fs.SetFolderDescription("test_user", "new_folder", "changed"); err != nil {
+		t.Fatalf("Failed to set description: %s", err)
+	}
+	if _, err := fs.Redo(); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if _, err := fs.Undo(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if folder := fs.getUserByUsername("test_user").getFolderByName("new_folder"); folder.Description != "test_description" {
+		t.Errorf("Expected the description to be restored but got '%s'", folder.Description)
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	fs := NewFileSystem(WithHistory(NewHistory()))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.DeleteFolder("test_user", "test_folder"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+
+	// Re-create a folder with the same name without the history
+	history := fs.history
+	fs.history = nil
+	if err := fs.CreateFolder("test_user", "test_folder", "other"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	fs.history = history
+
+	if _, err := fs.Undo(); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	folder := fs.getUserByUsername("test_user").getFolderByName("test_folder")
+	if folder == nil || folder.Description != "other" {
+		t.Errorf("Expected the re-created folder to be kept but got %+v", folder)
+	}
+
+	// The conflicting change is dropped, the creation and the registration before it
+	// conflict with the re-created folder too
+	for i := 0; i < 3; i++ {
+		if _, err := fs.Undo(); err == nil {
+			t.Errorf("Expected an error but got nil")
+		}
+	}
+	if len(fs.history.undo) != 0 {
+		t.Errorf("Expected the history to be empty but got %d changes", len(fs.history.undo))
+	}
+}
+
+func TestUndoMetadata(t *testing.T) {
+	fs := NewFileSystem(WithHistory(NewHistory()))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.AddTag("test_user", "test_folder", "", "work"); err != nil {
+		t.Fatalf("Failed to add tag: %s", err)
+	}
+	if err := fs.SetAttribute("test_user", "test_folder", "", "owner", "alice"); err != nil {
+		t.Fatalf("Failed to set attribute: %s", err)
+	}
+	if err := fs.SetUserQuota("test_user", Quota{Folders: 3}); err != nil {
+		t.Fatalf("Failed to set quota: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := fs.Undo(); err != nil {
+			t.Fatalf("Expected no error but got '%s'", err.Error())
+		}
+	}
+
+	folder := fs.getUserByUsername("test_user").getFolderByName("test_folder")
+	if folder.HasTag("work") {
+		t.Errorf("Expected the tag to be removed")
+	}
+	if _, err := fs.GetAttribute("test_user", "test_folder", "", "owner"); err == nil {
+		t.Errorf("Expected the attribute to be removed")
+	}
+	if fs.getUserByUsername("test_user").Quota != nil {
+		t.Errorf("Expected the default quota to apply again")
+	}
+}
+
+func TestUndoQuota(t *testing.T) {
+	fs := NewFileSystem(WithHistory(NewHistory()))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	for _, filename := range []string{"a.txt", "b.txt"} {
+		if err := fs.CreateFile("test_user", "test_folder", filename, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+	if err := fs.DeleteFile("test_user", "test_folder", "a.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	// The quota is lowered without going through the history
+	fs.defaultQuota = Quota{Files: 1}
+
+	// Restoring the deleted file would exceed the quota, so it conflicts
+	action, err := fs.Undo()
+	if err == nil || action != "delete-file test_user/test_folder/a.txt" {
+		t.Fatalf("Expected the deletion to conflict but got '%s' (%v)", action, err)
+	}
+	if usage, _, _ := fs.GetQuota("test_user"); usage.Files != 1 {
+		t.Errorf("Expected 1 file but got %d", usage.Files)
+	}
+}
+
+func TestRollbackIgnoresQuota(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %s", err)
+	}
+	if err := tx.SetUserQuota("test_user", Quota{Folders: 1, Files: 1}); err != nil {
+		t.Fatalf("Failed to set quota: %s", err)
+	}
+	if err := tx.DeleteFile("test_user", "test_folder", "test_file.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	if err := tx.DeleteFolder("test_user", "test_folder"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+	if err := tx.CreateFolder("test_user", "other_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back: %s", err)
+	}
+
+	// The rollback puts everything back, whatever the quota in between
+	if _, err := fs.ReadFile("test_user", "test_folder", "test_file.txt"); err != nil {
+		t.Errorf("Expected the file to be restored but got %s", err)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	fs := NewFileSystem(WithHistory(NewHistoryLimit(2)))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, foldername := range []string{"a", "b"} {
+		if err := fs.CreateFolder("test_user", foldername, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+
+	for _, expected := range []string{"create-folder test_user/b", "create-folder test_user/a"} {
+		if action, err := fs.Undo(); err != nil || action != expected {
+			t.Errorf("Expected %s to be undone but got '%s' (%v)", expected, action, err)
+		}
+	}
+	// The registration was dropped from the history
+	if _, err := fs.Undo(); err == nil {
+		t.Errorf("Expected nothing left to undo")
+	}
+}
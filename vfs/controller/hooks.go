@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+)
+
+// Call describes a call to a FileSystem as the hooks see it
+type Call struct {
+	// Operation is the name the call has in the metrics and the logs, such as "create-file"
+	Operation string
+	User      string
+	Folder    string
+	File      string
+	// Args holds the other arguments, such as the description of a new file.
+	// It is shared with the audit log and must not be changed.
+	Args map[string]string
+}
+
+// BeforeHook runs before a call does anything. Returning an error vetoes the call,
+// which returns that error.
+type BeforeHook func(call Call) error
+
+// AfterHook runs when a call returns, with the error it returns, nil on success
+type AfterHook func(call Call, err error)
+
+// hook is a registered hook, for one operation or for every one when operation is empty
+type hook[F any] struct {
+	operation string
+	order     int
+	fn        F
+}
+
+type hookRegistry struct {
+	mu     sync.RWMutex
+	before []*hook[BeforeHook]
+	after  []*hook[AfterHook]
+}
+
+// OnBefore registers a hook run before the calls of the operation, or before every call
+// when operation is empty. Hooks run by ascending order, and in the order they were
+// registered when orders are equal; the first one returning an error stops the call.
+// Before hooks run while the call holds the lock, so a veto inside a transaction rolls
+// it back like any failure, and they must not call the FileSystem. Rollback cannot be
+// vetoed, and a vetoed commit rolls the transaction back. The returned function removes
+// the hook.
+func (fs *FileSystem) OnBefore(operation string, order int, fn BeforeHook) (remove func()) {
+	r := &fs.hooks
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := &hook[BeforeHook]{operation: operation, order: order, fn: fn}
+	r.before = insertHook(r.before, h)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.before = removeHook(r.before, h)
+	}
+}
+
+// OnAfter registers a hook run when the calls of the operation return, or when every
+// call returns when operation is empty, vetoed calls included. Hooks run in the same
+// order as before hooks, once the call has released the lock. The hooks of the calls
+// of a transaction are held back until it is committed and dropped when it is rolled
+// back, except those of the failing call rolling it back, which get its error at once.
+// The returned function removes the hook.
+func (fs *FileSystem) OnAfter(operation string, order int, fn AfterHook) (remove func()) {
+	r := &fs.hooks
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := &hook[AfterHook]{operation: operation, order: order, fn: fn}
+	r.after = insertHook(r.after, h)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.after = removeHook(r.after, h)
+	}
+}
+
+// insertHook returns a copy of hooks with h in its place, the slices are replaced rather
+// than changed so the hooks of a running call can be read without the lock
+func insertHook[F any](hooks []*hook[F], h *hook[F]) []*hook[F] {
+	i := sort.Search(len(hooks), func(i int) bool { return hooks[i].order > h.order })
+	inserted := make([]*hook[F], 0, len(hooks)+1)
+	inserted = append(inserted, hooks[:i]...)
+	inserted = append(inserted, h)
+	return append(inserted, hooks[i:]...)
+}
+
+func removeHook[F any](hooks []*hook[F], h *hook[F]) []*hook[F] {
+	removed := make([]*hook[F], 0, len(hooks))
+	for _, other := range hooks {
+		if other != h {
+			removed = append(removed, other)
+		}
+	}
+	return removed
+}
+
+// before runs the before hooks of the call and returns the first veto
+func (fs *FileSystem) before(call Call) error {
+	fs.hooks.mu.RLock()
+	hooks := fs.hooks.before
+	fs.hooks.mu.RUnlock()
+	for _, h := range hooks {
+		if h.operation != "" && h.operation != call.Operation {
+			continue
+		}
+		if err := h.fn(call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// after runs the after hooks of the call, or holds them back until its transaction is committed
+func (fs *FileSystem) after(call Call, err error) {
+	if fs.tx != nil && !fs.tx.done {
+		fs.tx.calls = append(fs.tx.calls, pendingCall{call: call, err: err})
+		return
+	}
+	fs.hooks.mu.RLock()
+	hooks := fs.hooks.after
+	fs.hooks.mu.RUnlock()
+	for _, h := range hooks {
+		if h.operation == "" || h.operation == call.Operation {
+			h.fn(call, err)
+		}
+	}
+}
+
+// beforeUnlocked runs the before hooks of a call which takes the lock in several steps,
+// holding the lock like the other calls do. A veto of a call changing the state rolls
+// the transaction in progress back like the failures of such calls.
+func (fs *FileSystem) beforeUnlocked(call Call, changes bool) (err error) {
+	if !changes {
+		if err := fs.rlock(); err != nil {
+			return err
+		}
+		defer fs.runlock()
+		return fs.before(call)
+	}
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	return fs.before(call)
+}
@@ -0,0 +1,317 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBeforeHookVeto(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "releases", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	fs.OnBefore("create-file", 0, func(call Call) error {
+		if call.Folder == "releases" && call.Args["description"] == "" {
+			return fmt.Errorf("Error: Files in releases need a description.")
+		}
+		return nil
+	})
+
+	err := fs.CreateFile("test_user", "releases", "v1.zip", "")
+	if err == nil || err.Error() != "Error: Files in releases need a description." {
+		t.Fatalf("Expected the veto but got %v", err)
+	}
+	if _, err := fs.ReadFile("test_user", "releases", "v1.zip"); err == nil {
+		t.Errorf("Expected the vetoed file not to exist")
+	}
+	if err := fs.CreateFile("test_user", "releases", "v1.zip", "First release"); err != nil {
+		t.Errorf("Expected the file to be created but got %s", err)
+	}
+}
+
+func TestBeforeHookVetoRollsBackTransaction(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	fs.OnBefore("create-file", 0, func(call Call) error {
+		return fmt.Errorf("Error: No files today.")
+	})
+
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %s", err)
+	}
+	if err := tx.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := tx.CreateFile("test_user", "test_folder", "test_file", ""); err == nil {
+		t.Fatalf("Expected the veto but got nil")
+	}
+	if !tx.Done() {
+		t.Errorf("Expected the veto to roll back the transaction")
+	}
+	if names, _ := fs.GlobFolders("test_user", "*"); len(names) != 0 {
+		t.Errorf("Expected the folder to be rolled back but got %v", names)
+	}
+}
+
+func TestBeforeHookVetoCommit(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	remove := fs.OnBefore("commit", 0, func(call Call) error {
+		return fmt.Errorf("Error: No commits today.")
+	})
+
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %s", err)
+	}
+	if err := tx.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatalf("Expected the veto but got nil")
+	}
+	if !tx.Done() {
+		t.Errorf("Expected the veto to roll back the transaction")
+	}
+
+	// The lock is released, so the next calls don't wait forever, even after the
+	// commit of an import is vetoed too
+	done := make(chan error, 1)
+	go func() {
+		if _, err := fs.Import("test_user", t.TempDir(), ImportSkip); err == nil {
+			done <- fmt.Errorf("Expected the veto of the import but got nil")
+			return
+		}
+		done <- fs.CreateFolder("test_user", "other_folder", "")
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error but got '%s'", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the lock to be released after a vetoed commit")
+	}
+	remove()
+
+	if names, _ := fs.GlobFolders("test_user", "*"); len(names) != 1 || names[0] != "other_folder" {
+		t.Errorf("Expected only other_folder but got %v", names)
+	}
+}
+
+func TestAfterHook(t *testing.T) {
+	fs := NewFileSystem()
+	var deleted []string
+	var results []error
+	fs.OnAfter("delete-file", 0, func(call Call, err error) {
+		deleted = append(deleted, call.User+"/"+call.Folder+"/"+call.File)
+		results = append(results, err)
+	})
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	if err := fs.DeleteFile("test_user", "test_folder", "test_file"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	if err := fs.DeleteFile("test_user", "test_folder", "test_file"); err == nil {
+		t.Fatalf("Expected an error but got nil")
+	}
+
+	expected := []string{"test_user/test_folder/test_file", "test_user/test_folder/test_file"}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("Expected %v but got %v", expected, deleted)
+	}
+	if len(results) != 2 || results[0] != nil || results[1] == nil {
+		t.Errorf("Expected a success then a failure but got %v", results)
+	}
+}
+
+func TestAfterHookCanCallFileSystem(t *testing.T) {
+	fs := NewFileSystem()
+	var listing string
+	fs.OnAfter("create-folder", 0, func(call Call, err error) {
+		listing, _ = fs.ListFolders(call.User, "", "")
+	})
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if listing == "" {
+		t.Errorf("Expected the hook to list the new folder")
+	}
+}
+
+func TestHookOrder(t *testing.T) {
+	fs := NewFileSystem()
+	var order []string
+	add := func(name string, position int) func() {
+		return fs.OnBefore("", position, func(call Call) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+	add("second", 10)
+	add("first", -5)
+	remove := add("removed", 10)
+	add("third", 10)
+	fs.OnBefore("create-folder", 20, func(call Call) error {
+		order = append(order, "other operation")
+		return nil
+	})
+	remove()
+
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	expected := []string{"first", "second", "third"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %v but got %v", expected, order)
+	}
+}
+
+func TestBeforeHookStopsAtFirstVeto(t *testing.T) {
+	fs := NewFileSystem()
+	ran := false
+	fs.OnBefore("", 1, func(call Call) error {
+		return fmt.Errorf("Error: Closed.")
+	})
+	fs.OnBefore("", 2, func(call Call) error {
+		ran = true
+		return nil
+	})
+	var vetoed error
+	fs.OnAfter("", 0, func(call Call, err error) {
+		vetoed = err
+	})
+
+	if err := fs.Register("test_user"); err == nil {
+		t.Fatalf("Expected the veto but got nil")
+	}
+	if ran {
+		t.Errorf("Expected the later hook not to run")
+	}
+	if vetoed == nil {
+		t.Errorf("Expected the after hook to receive the veto")
+	}
+}
+
+func TestAfterHookTransaction(t *testing.T) {
+	fs := NewFileSystem()
+	var deleted []string
+	fs.OnAfter("delete-folder", 0, func(call Call, err error) {
+		if err == nil {
+			deleted = append(deleted, call.Folder)
+		}
+	})
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, foldername := range []string{"a", "b"} {
+		if err := fs.CreateFolder("test_user", foldername, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+
+	// A rolled back delete never happened, so its hooks are dropped
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %s", err)
+	}
+	if err := tx.DeleteFolder("test_user", "a"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back: %s", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("Expected no hook for a rolled back delete but got %v", deleted)
+	}
+
+	// A committed delete runs its hooks on commit, and they can call the FileSystem
+	tx, err = fs.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %s", err)
+	}
+	var listing string
+	remove := fs.OnAfter("delete-folder", 1, func(call Call, err error) {
+		listing, _ = fs.ListFolders(call.User, "", "")
+	})
+	defer remove()
+	if err := tx.DeleteFolder("test_user", "b"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("Expected the hooks to wait for the commit but got %v", deleted)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %s", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"b"}) || !strings.Contains(listing, "a") {
+		t.Errorf("Expected the hooks of b after the commit but got %v and '%s'", deleted, listing)
+	}
+}
+
+func TestBeforeHookVetoImportAndSync(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	fs.OnBefore("import", 0, func(call Call) error {
+		return fmt.Errorf("Error: No imports.")
+	})
+	fs.OnBefore("sync", 0, func(call Call) error {
+		return fmt.Errorf("Error: No syncs.")
+	})
+
+	// Like any failing call, a veto rolls back the transaction in progress
+	for _, run := range []func(tx *Tx) error{
+		func(tx *Tx) error {
+			_, err := tx.Import("test_user", t.TempDir(), ImportSkip)
+			return err
+		},
+		func(tx *Tx) error {
+			_, err := tx.Sync("test_user", "test_folder", t.TempDir(), SyncBoth, false)
+			return err
+		},
+	} {
+		tx, err := fs.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin: %s", err)
+		}
+		if err := tx.CreateFolder("test_user", "other_folder", ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+		if err := run(tx); err == nil {
+			t.Fatalf("Expected the veto but got nil")
+		}
+		if !tx.Done() {
+			t.Errorf("Expected the veto to roll back the transaction")
+		}
+		if names, _ := fs.GlobFolders("test_user", "other_folder"); len(names) != 0 {
+			t.Errorf("Expected the folder to be rolled back but got %v", names)
+		}
+	}
+}
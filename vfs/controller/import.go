@@ -0,0 +1,520 @@
+package controller
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iscool/vfs/controller/validate"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxImportBytes is the most file content an import reads into memory, when the byte
+// quota of the user doesn't stop it sooner
+const MaxImportBytes = 1 << 30
+
+// errTooLarge is returned by readLimited when the reader holds more than the limit
+var errTooLarge = errors.New("too large")
+
+// ImportPolicy decides what Import does with a folder or file name the file system refuses
+type ImportPolicy int
+
+const (
+	// ImportSkip leaves the folder or file out
+	ImportSkip ImportPolicy = iota
+	// ImportRename replaces the invalid chars, shortens the name and numbers it until it is free
+	ImportRename
+)
+
+// String returns the name of the import policy
+func (p ImportPolicy) String() string {
+	switch p {
+	case ImportSkip:
+		return "skip"
+	case ImportRename:
+		return "rename"
+	}
+	return "unknown"
+}
+
+// ParseImportPolicy returns the import policy with the given name
+func ParseImportPolicy(name string) (ImportPolicy, error) {
+	for _, p := range []ImportPolicy{ImportSkip, ImportRename} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return ImportSkip, fmt.Errorf("Error: Unknown import policy %s. Valid policies are 'skip' 'rename'", name)
+}
+
+// ImportReport tells what Import created and which entries it renamed or skipped
+type ImportReport struct {
+	Folders int
+	Files   int
+	// Issues lists the renamed and skipped entries in the order they were met
+	Issues []ImportIssue
+}
+
+// ImportIssue is an entry of the source that could not be imported as it is
+type ImportIssue struct {
+	// Path is the slash separated path of the entry in the source
+	Path string
+	// NewName is the name given by ImportRename, it is empty when the entry was skipped
+	NewName string
+	Reason  string
+}
+
+// String formats the issue for display
+func (i ImportIssue) String() string {
+	if i.NewName == "" {
+		return fmt.Sprintf("Warning: Skipped %s. %s", i.Path, i.Reason)
+	}
+	return fmt.Sprintf("Warning: Renamed %s to %s. %s", i.Path, i.NewName, i.Reason)
+}
+
+// Import creates a folder for each directory of src and a file for each file inside it.
+// src is read like Export writes it: a tar.gz or zip archive, or else a directory.
+// Names go through the same validation as CreateFolder and CreateFile, and the ones
+// refused or already taken are renamed or skipped following the policy. Descriptions,
+// timestamps, tags and attributes are restored from the manifest when there is one.
+// The import applies completely or not at all, and is undone as a whole. Reading stops
+// with a quota error as soon as the content goes past the remaining byte quota.
+func (fs *FileSystem) Import(username, src string, policy ImportPolicy) (report *ImportReport, err error) {
+	call := Call{Operation: "import", User: username, Args: map[string]string{"src": src, "policy": policy.String()}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.beforeUnlocked(call, true); err != nil {
+		return nil, err
+	}
+	limit, exceeded, err := fs.importLimit(username)
+	if err != nil {
+		return nil, err
+	}
+	source, err := readImportSource(src, limit, exceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.atomically(func(run *FileSystem) error {
+		report, err = run.importSource(username, source, policy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importLimit returns how much file content an import for the user may read and the
+// error reported past it: the remaining byte quota, or MaxImportBytes when it is lower
+func (fs *FileSystem) importLimit(username string) (limit int64, exceeded error, err error) {
+	if err := fs.rlock(); err != nil {
+		return 0, nil, err
+	}
+	defer fs.runlock()
+
+	limit = MaxImportBytes
+	exceeded = newError(ErrInvalid, "Error: The import holds more than %d bytes of content.", MaxImportBytes)
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return limit, exceeded, nil
+	}
+	if quota := fs.quotaOf(user); quota.Bytes > 0 && quota.Bytes-user.usage.Bytes < limit {
+		limit = max(quota.Bytes-user.usage.Bytes, 0)
+		exceeded = &QuotaError{Username: user.Name, Resource: "bytes", Limit: quota.Bytes}
+	}
+	return limit, exceeded, nil
+}
+
+// importSource creates the folders and files of the source, the lock must be held
+func (fs *FileSystem) importSource(username string, source *importSource, policy ImportPolicy) (*ImportReport, error) {
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil, newError(ErrNotFound, "Error: %s doesn't exist.", username)
+	}
+
+	report := &ImportReport{Issues: source.issues}
+	for _, dir := range source.folders {
+		foldername, ok := fs.importName(entityFolder, dir.name, dir.name, user.isFolderExists, policy, report)
+		if !ok {
+			continue
+		}
+		folderMeta := source.manifest.folder(dir.name)
+		if err := fs.CreateFolder(username, foldername, folderMeta.Description); err != nil {
+			return nil, err
+		}
+		folder := user.getFolderByName(foldername)
+		foldername = folder.Name
+		report.Folders++
+
+		for _, entry := range dir.files {
+			filename, ok := fs.importName(entityFile, entry.name, dir.name+"/"+entry.name, folder.isFileExists, policy, report)
+			if !ok {
+				continue
+			}
+			fileMeta := folderMeta.file(entry.name)
+			if err := fs.CreateFile(username, foldername, filename, fileMeta.Description); err != nil {
+				return nil, err
+			}
+			file := folder.getFileByName(filename)
+			if len(entry.content) > 0 {
+				if err := fs.WriteFile(username, foldername, file.Name, entry.content); err != nil {
+					return nil, err
+				}
+			}
+			if err := fs.restoreMetadata(username, foldername, file.Name, dir.name+"/"+entry.name, fileMeta.Tags, fileMeta.Attributes, report); err != nil {
+				return nil, err
+			}
+			folder.fileIndex.update(file, func() { restoreTimes(&file.CreatedAt, &file.ModifiedAt, fileMeta.CreatedAt, fileMeta.ModifiedAt) })
+			report.Files++
+		}
+
+		if err := fs.restoreMetadata(username, foldername, "", dir.name, folderMeta.Tags, folderMeta.Attributes, report); err != nil {
+			return nil, err
+		}
+		// The folder was modified by each file, so its times are restored last
+		user.folderIndex.update(folder, func() {
+			restoreTimes(&folder.CreatedAt, &folder.ModifiedAt, folderMeta.CreatedAt, folderMeta.ModifiedAt)
+		})
+	}
+	return report, nil
+}
+
+// importName returns the name to import an entry under, or false when it is skipped
+func (fs *FileSystem) importName(kind entity, name, path string, taken func(string) bool, policy ImportPolicy, report *ImportReport) (string, bool) {
+	err := fs.validateName(kind, validate.Normalize(name))
+	if err == nil && taken(name) {
+		err = newError(ErrAlreadyExists, "Error: The %s has already existed.", name)
+	}
+	if err == nil {
+		return name, true
+	}
+
+	issue := ImportIssue{Path: path, Reason: strings.TrimPrefix(err.Error(), "Error: ")}
+	if policy == ImportRename {
+		issue.NewName = fs.renameForImport(kind, name, taken)
+	}
+	report.Issues = append(report.Issues, issue)
+	return issue.NewName, issue.NewName != ""
+}
+
+// maxImportRenames bounds the numbers tried by renameForImport, past it the entry is skipped
+const maxImportRenames = 1000
+
+// renameForImport turns a refused name into a valid one that isn't taken, or returns
+// an empty name when the policy refuses every attempt
+func (fs *FileSystem) renameForImport(kind entity, name string, taken func(string) bool) string {
+	var b strings.Builder
+	for _, r := range validate.Normalize(name) {
+		if validate.ValidateChars(string(r), fs.policy.InvalidChars, fs.policy.AllowedChars) {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	base, ext := b.String(), ""
+	if kind == entityFile {
+		ext = path.Ext(base)
+		base = strings.TrimSuffix(base, ext)
+	}
+	if base == "" {
+		base = "imported"
+	}
+
+	limit := fs.policy.MaxFolder
+	if kind == entityFile {
+		limit = fs.policy.MaxFile
+	}
+	for i := 0; i < maxImportRenames; i++ {
+		suffix := ""
+		if i > 0 {
+			suffix = "_" + strconv.Itoa(i)
+		}
+		candidate := truncateRunes(base, limit-utf8.RuneCountInString(suffix+ext)) + suffix + ext
+		if fs.validateName(kind, candidate) == nil && !taken(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// truncateRunes keeps the first n runes of s, all of them when n isn't positive
+func truncateRunes(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// restoreMetadata attaches the tags and attributes of the manifest, skipping the invalid ones
+func (fs *FileSystem) restoreMetadata(username, foldername, filename, path string, tags []string, attributes map[string]string, report *ImportReport) error {
+	for _, tag := range tags {
+		if tag == "" || validate.ValidateNoInvalidChars(tag) {
+			report.Issues = append(report.Issues, ImportIssue{Path: path, Reason: fmt.Sprintf("The tag %s contains invalid chars.", tag)})
+			continue
+		}
+		if err := fs.AddTag(username, foldername, filename, tag); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "" || validate.ValidateNoInvalidChars(key) {
+			report.Issues = append(report.Issues, ImportIssue{Path: path, Reason: fmt.Sprintf("The attribute %s contains invalid chars.", key)})
+			continue
+		}
+		if err := fs.SetAttribute(username, foldername, filename, key, attributes[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreTimes replaces the times that the manifest gives
+func restoreTimes(createdAt, modifiedAt *time.Time, created, modified time.Time) {
+	if !created.IsZero() {
+		*createdAt = created
+	}
+	if !modified.IsZero() {
+		*modifiedAt = modified
+	}
+}
+
+// importSource is what Import found in a directory or an archive
+type importSource struct {
+	folders  []*importFolder
+	manifest *Manifest
+	issues   []ImportIssue
+
+	byName map[string]*importFolder
+	nested map[string]bool
+	// remaining is how much more file content may be read, exceeded is returned past it
+	remaining int64
+	exceeded  error
+}
+
+type importFolder struct {
+	name  string
+	files []importFile
+}
+
+type importFile struct {
+	name    string
+	content []byte
+}
+
+// folder returns the manifest of the folder, empty when the manifest doesn't list it
+func (m *Manifest) folder(name string) *FolderManifest {
+	if m != nil {
+		for i := range m.Folders {
+			if m.Folders[i].Name == name {
+				return &m.Folders[i]
+			}
+		}
+	}
+	return &FolderManifest{}
+}
+
+// file returns the manifest of the file, empty when the manifest doesn't list it
+func (m *FolderManifest) file(name string) *FileManifest {
+	for i := range m.Files {
+		if m.Files[i].Name == name {
+			return &m.Files[i]
+		}
+	}
+	return &FileManifest{}
+}
+
+func readImportSource(src string, limit int64, exceeded error) (*importSource, error) {
+	source := &importSource{byName: make(map[string]*importFolder), nested: make(map[string]bool), remaining: limit, exceeded: exceeded}
+
+	var err error
+	lower := strings.ToLower(src)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		err = source.readTarGz(src)
+	case strings.HasSuffix(lower, ".zip"):
+		err = source.readZip(src)
+	default:
+		err = source.readDir(src)
+	}
+	if errors.Is(err, exceeded) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, newError(ErrIO, "Error: Cannot import %s: %v", src, err)
+	}
+
+	sort.Slice(source.folders, func(i, j int) bool { return source.folders[i].name < source.folders[j].name })
+	for _, folder := range source.folders {
+		files := folder.files
+		sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	}
+	return source, nil
+}
+
+// add places an entry of the source, named with a slash separated path. Only the
+// directories at the top and the regular files inside them can be imported.
+// read returns the content of the entry, failing with errTooLarge past the limit.
+func (s *importSource) add(name string, dir, regular bool, read func(limit int64) ([]byte, error)) error {
+	// Cleaning from the root keeps the entry inside the source
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+	parts := strings.Split(name, "/")
+
+	switch {
+	case !dir && !regular:
+		s.issues = append(s.issues, ImportIssue{Path: name, Reason: "Only directories and regular files can be imported."})
+	case len(parts) == 1 && dir:
+		s.folder(name)
+	case len(parts) == 1 && name == ManifestName:
+		data, err := read(MaxImportBytes)
+		if err != nil {
+			return err
+		}
+		s.manifest = &Manifest{}
+		if err := json.Unmarshal(data, s.manifest); err != nil {
+			return fmt.Errorf("invalid manifest: %v", err)
+		}
+	case len(parts) == 1:
+		s.issues = append(s.issues, ImportIssue{Path: name, Reason: "Only the files inside a folder can be imported."})
+	case len(parts) == 2 && !dir:
+		content, err := read(s.remaining)
+		if errors.Is(err, errTooLarge) {
+			return s.exceeded
+		}
+		if err != nil {
+			return err
+		}
+		s.remaining -= int64(len(content))
+		folder := s.folder(parts[0])
+		folder.files = append(folder.files, importFile{name: parts[1], content: content})
+	default:
+		// Report a nested directory once, not each entry below it
+		nested := parts[0] + "/" + parts[1]
+		if !s.nested[nested] {
+			s.nested[nested] = true
+			s.issues = append(s.issues, ImportIssue{Path: nested, Reason: "Nested directories cannot be imported."})
+		}
+	}
+	return nil
+}
+
+// folder returns the folder of the source with the name, adding it if needed
+func (s *importSource) folder(name string) *importFolder {
+	folder, ok := s.byName[name]
+	if !ok {
+		folder = &importFolder{name: name}
+		s.byName[name] = folder
+		s.folders = append(s.folders, folder)
+	}
+	return folder
+}
+
+func (s *importSource) readDir(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory, .tar.gz, .tgz or .zip archive")
+	}
+
+	return filepath.WalkDir(root, func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		return s.add(filepath.ToSlash(rel), entry.IsDir(), entry.Type().IsRegular(), func(limit int64) ([]byte, error) {
+			f, err := os.Open(p)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return readLimited(f, limit)
+		})
+	})
+}
+
+func (s *importSource) readTarGz(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	r := tar.NewReader(gz)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dir := header.Typeflag == tar.TypeDir
+		regular := header.Typeflag == tar.TypeReg
+		if err := s.add(header.Name, dir, regular, func(limit int64) ([]byte, error) { return readLimited(r, limit) }); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *importSource) readZip(src string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		mode := f.Mode()
+		read := func(limit int64) ([]byte, error) {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return readLimited(rc, limit)
+		}
+		if err := s.add(f.Name, mode.IsDir(), mode.IsRegular(), read); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLimited reads r whole, or fails with errTooLarge once it holds more than limit bytes
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errTooLarge
+	}
+	return data, nil
+}
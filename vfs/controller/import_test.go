@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImportRoundTrip(t *testing.T) {
+	for _, name := range []string{"out", "out.tar.gz", "out.zip"} {
+		t.Run(name, func(t *testing.T) {
+			source, start := newExportFileSystem(t)
+			dest := filepath.Join(t.TempDir(), name)
+			if _, err := source.Export("test_user", "", dest); err != nil {
+				t.Fatalf("Failed to export: %s", err)
+			}
+
+			fs := NewFileSystem()
+			if err := fs.Register("other_user"); err != nil {
+				t.Fatalf("Failed to register user: %s", err)
+			}
+			report, err := fs.Import("other_user", dest, ImportSkip)
+			if err != nil {
+				t.Fatalf("Expected no error but got '%s'", err.Error())
+			}
+			if report.Folders != 2 || report.Files != 2 || len(report.Issues) != 0 {
+				t.Errorf("Expected 2 folders, 2 files and no issues but got %+v", report)
+			}
+
+			content, err := fs.ReadFile("other_user", "docs", "a.txt")
+			if err != nil || string(content) != "hello" {
+				t.Errorf("Expected 'hello' but got '%s' (%v)", content, err)
+			}
+			folder := fs.Users["other_user"].Folders["docs"]
+			file := folder.Files["a.txt"]
+			if folder.Description != "the docs" || !folder.CreatedAt.Equal(start) {
+				t.Errorf("Expected the folder to be restored but got %+v", folder)
+			}
+			if file.Description != "first file" || !file.CreatedAt.Equal(start.Add(time.Hour)) || !file.HasTag("draft") {
+				t.Errorf("Expected the file to be restored but got %+v", file)
+			}
+		})
+	}
+}
+
+func TestImportDirectory(t *testing.T) {
+	src := t.TempDir()
+	for _, dir := range []string{"docs", "bad name", "docs/nested"} {
+		if err := os.Mkdir(filepath.Join(src, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %s", err)
+		}
+	}
+	for name, content := range map[string]string{
+		"docs/a.txt":        "a",
+		"docs/b c.txt":      "b",
+		"docs/nested/d.txt": "d",
+		"bad name/e.txt":    "e",
+		"top.txt":           "top",
+	} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %s", err)
+		}
+	}
+
+	// Test importing for a user that doesn't exist
+	fs := NewFileSystem()
+	_, err := fs.Import("test_user", src, ImportSkip)
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+
+	report, err := fs.Import("test_user", src, ImportSkip)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if report.Folders != 1 || report.Files != 1 {
+		t.Errorf("Expected 1 folder and 1 file but got %+v", report)
+	}
+	expected := []string{
+		"Warning: Skipped docs/nested. Nested directories cannot be imported.",
+		"Warning: Skipped top.txt. Only the files inside a folder can be imported.",
+		"Warning: Skipped bad name. The bad name contains invalid chars.",
+		"Warning: Skipped docs/b c.txt. The b c.txt contains invalid chars.",
+	}
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues but got %v", len(expected), report.Issues)
+	}
+	for i, issue := range report.Issues {
+		if issue.String() != expected[i] {
+			t.Errorf("Expected '%s' but got '%s'", expected[i], issue.String())
+		}
+	}
+
+	// Test importing again, renaming the folders and files that are refused or taken
+	report, err = fs.Import("test_user", src, ImportRename)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if report.Folders != 2 || report.Files != 3 {
+		t.Errorf("Expected 2 folders and 3 files but got %+v", report)
+	}
+	for _, path := range [][2]string{{"bad_name", "e.txt"}, {"docs_1", "a.txt"}, {"docs_1", "b_c.txt"}} {
+		if _, err := fs.ReadFile("test_user", path[0], path[1]); err != nil {
+			t.Errorf("Expected %s/%s to be imported but got '%s'", path[0], path[1], err)
+		}
+	}
+}
+
+func TestImportAtomic(t *testing.T) {
+	src := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(src, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %s", err)
+		}
+	}
+
+	fs := NewFileSystem(WithDefaultQuota(Quota{Folders: 1}), WithHistory(NewHistory()))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+
+	// Test an import going over the quota, which leaves nothing behind
+	_, err := fs.Import("test_user", src, ImportSkip)
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if len(fs.Users["test_user"].Folders) != 0 {
+		t.Errorf("Expected no folders but got %d", len(fs.Users["test_user"].Folders))
+	}
+
+	// Test undoing an import as a whole
+	fs.defaultQuota = Quota{}
+	if _, err := fs.Import("test_user", src, ImportSkip); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if _, err := fs.Undo(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if len(fs.Users["test_user"].Folders) != 0 {
+		t.Errorf("Expected no folders but got %d", len(fs.Users["test_user"].Folders))
+	}
+}
+
+func TestImportQuotaBytes(t *testing.T) {
+	for _, name := range []string{"out", "out.tar.gz", "out.zip"} {
+		t.Run(name, func(t *testing.T) {
+			source, _ := newExportFileSystem(t)
+			dest := filepath.Join(t.TempDir(), name)
+			if _, err := source.Export("test_user", "", dest); err != nil {
+				t.Fatalf("Failed to export: %s", err)
+			}
+
+			// The 5 bytes of a.txt don't fit in the 4 bytes left
+			fs := NewFileSystem(WithDefaultQuota(Quota{Bytes: 4}))
+			if err := fs.Register("other_user"); err != nil {
+				t.Fatalf("Failed to register user: %s", err)
+			}
+			_, err := fs.Import("other_user", dest, ImportSkip)
+			var quotaErr *QuotaError
+			if !errors.As(err, &quotaErr) || quotaErr.Resource != "bytes" || quotaErr.Limit != 4 {
+				t.Fatalf("Expected a bytes quota error but got '%v'", err)
+			}
+			if len(fs.Users["other_user"].Folders) != 0 {
+				t.Errorf("Expected no folders but got %d", len(fs.Users["other_user"].Folders))
+			}
+
+			// The content fits exactly once the quota is raised
+			if err := fs.SetDefaultQuota(Quota{Bytes: 5}); err != nil {
+				t.Fatalf("Failed to set quota: %s", err)
+			}
+			if _, err := fs.Import("other_user", dest, ImportSkip); err != nil {
+				t.Errorf("Expected no error but got '%s'", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListOption changes which entries a listing returns
+type ListOption func(*listOptions)
+
+type listOptions struct {
+	tag   string
+	limit int
+	after string
+}
+
+// WithTag only lists the entries carrying the tag
+func WithTag(tag string) ListOption {
+	return func(o *listOptions) {
+		o.tag = tag
+	}
+}
+
+// WithLimit lists at most limit entries, zero means no limit
+func WithLimit(limit int) ListOption {
+	return func(o *listOptions) {
+		o.limit = limit
+	}
+}
+
+// WithAfter lists the entries following the cursor returned with the previous page.
+// The cursor holds the sort key of the last entry listed, so the next page starts at
+// the right place even if entries were added or removed in between.
+func WithAfter(cursor string) ListOption {
+	return func(o *listOptions) {
+		o.after = cursor
+	}
+}
+
+// newListOptions applies the options over the defaults
+func newListOptions(opts []ListOption) listOptions {
+	var o listOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// match reports whether an entry with the metadata is listed
+func (o listOptions) match(metadata *Metadata) bool {
+	return o.tag == "" || metadata.HasTag(o.tag)
+}
+
+// validateSort checks the sort flags shared by the listings
+func validateSort(sortBy string, sortOrder string) error {
+	switch sortBy + " " + sortOrder {
+	case "--sort-name asc", "--sort-name desc", "--sort-created asc", "--sort-created desc", "--sort-modified asc", "--sort-modified desc", " ":
+		return nil
+	}
+	return fmt.Errorf("Error: Unknown flag. Valid flags are '--sort-name asc' '--sort-name desc' '--sort-created asc' '--sort-created desc' '--sort-modified asc' '--sort-modified desc'")
+}
+
+// sortKey orders listed entries by time when sorting by a timestamp, then by name
+type sortKey struct {
+	time int64
+	name string
+}
+
+// newSortKey returns the key of an entry for the sort flag
+func newSortKey(sortBy string, name string, createdAt time.Time, modifiedAt time.Time) sortKey {
+	switch sortBy {
+	case "--sort-created":
+		return sortKey{time: createdAt.UnixNano(), name: name}
+	case "--sort-modified":
+		return sortKey{time: modifiedAt.UnixNano(), name: name}
+	}
+	return sortKey{name: name}
+}
+
+// before reports whether k is listed before other in ascending order
+func (k sortKey) before(other sortKey) bool {
+	if k.time != other.time {
+		return k.time < other.time
+	}
+	return k.name < other.name
+}
+
+// encodeCursor turns the key of the last entry of a page into an opaque cursor
+func encodeCursor(sortBy string, key sortKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sortBy + "|" + strconv.FormatInt(key.time, 10) + "|" + key.name))
+}
+
+// decodeCursor returns the key held by a cursor made for the same sort flag
+func decodeCursor(sortBy string, cursor string) (sortKey, error) {
+	invalid := fmt.Errorf("Error: Invalid cursor %s for this sort order.", cursor)
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return sortKey{}, invalid
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 || parts[0] != sortBy {
+		return sortKey{}, invalid
+	}
+	t, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return sortKey{}, invalid
+	}
+	return sortKey{time: t, name: parts[2]}, nil
+}
+
+// listed is a folder or a file as kept in an orderedIndex
+type listed interface {
+	*Folder | *File
+	sortKey(sortBy string) sortKey
+	metadata() *Metadata
+}
+
+// orderedIndex keeps the folders of a user or the files of a folder sorted for
+// every sort flag, so that a page is listed without sorting everything first
+type orderedIndex[T listed] struct {
+	byName     skipList[T]
+	byCreated  skipList[T]
+	byModified skipList[T]
+}
+
+// list returns the skip list ordered for the sort flag
+func (x *orderedIndex[T]) list(sortBy string) *skipList[T] {
+	switch sortBy {
+	case "--sort-created":
+		return &x.byCreated
+	case "--sort-modified":
+		return &x.byModified
+	}
+	return &x.byName
+}
+
+func (x *orderedIndex[T]) insert(value T) {
+	x.byName.insert(value.sortKey("--sort-name"), value)
+	x.byCreated.insert(value.sortKey("--sort-created"), value)
+	x.byModified.insert(value.sortKey("--sort-modified"), value)
+}
+
+func (x *orderedIndex[T]) remove(value T) {
+	x.byName.remove(value.sortKey("--sort-name"))
+	x.byCreated.remove(value.sortKey("--sort-created"))
+	x.byModified.remove(value.sortKey("--sort-modified"))
+}
+
+// update applies a change to the name or the timestamps of the value and moves it to its new place
+func (x *orderedIndex[T]) update(value T, change func()) {
+	x.remove(value)
+	change()
+	x.insert(value)
+}
+
+func (f *Folder) sortKey(sortBy string) sortKey {
+	return newSortKey(sortBy, f.Name, f.CreatedAt, f.ModifiedAt)
+}
+
+func (f *Folder) metadata() *Metadata {
+	return &f.Metadata
+}
+
+func (f *File) sortKey(sortBy string) sortKey {
+	return newSortKey(sortBy, f.Name, f.CreatedAt, f.ModifiedAt)
+}
+
+func (f *File) metadata() *Metadata {
+	return &f.Metadata
+}
+
+// page walks the index in the order of the sort flags and returns the page selected
+// by the options, with the cursor of the next page or an empty cursor on the last page
+func page[T listed](index *orderedIndex[T], sortBy string, sortOrder string, o listOptions) ([]T, string, error) {
+	if sortBy == "" {
+		sortBy = "--sort-name"
+	}
+	list := index.list(sortBy)
+	desc := sortOrder == "desc"
+
+	var n *skipNode[T]
+	switch {
+	case o.after != "":
+		after, err := decodeCursor(sortBy, o.after)
+		if err != nil {
+			return nil, "", err
+		}
+		if desc {
+			n = list.before(after)
+		} else {
+			n = list.after(after)
+		}
+	case desc:
+		n = list.last()
+	default:
+		n = list.first()
+	}
+
+	var entries []T
+	for ; n != nil; n = step(n, desc) {
+		if !o.match(n.value.metadata()) {
+			continue
+		}
+		if o.limit > 0 && len(entries) == o.limit {
+			last := entries[len(entries)-1]
+			return entries, encodeCursor(sortBy, last.sortKey(sortBy)), nil
+		}
+		entries = append(entries, n.value)
+	}
+	return entries, "", nil
+}
+
+// step moves to the next node in ascending or descending order
+func step[T any](n *skipNode[T], desc bool) *skipNode[T] {
+	if desc {
+		return n.prev
+	}
+	return n.next[0]
+}
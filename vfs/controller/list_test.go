@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listNames returns the first word of every line of a listing
+func listNames(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		names = append(names, strings.Fields(line)[0])
+	}
+	return names
+}
+
+func TestListFilesPage(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := fs.CreateFile("test_user", "test_folder", fmt.Sprintf("file%d.txt", i), ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+
+	// Give every file its own creation time, in reverse name order
+	base := time.Now().Add(-time.Hour)
+	folder := fs.getUserByUsername("test_user").Folders["test_folder"]
+	for name, file := range folder.Files {
+		folder.fileIndex.update(file, func() {
+			file.CreatedAt = base.Add(-time.Duration(name[4]-'0') * time.Minute)
+		})
+	}
+
+	output, next, err := fs.ListFilesPage("test_user", "test_folder", "--sort-created", "asc", WithLimit(2))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if got := strings.Join(listNames(output), " "); got != "file5.txt file4.txt" || next == "" {
+		t.Fatalf("Expected file5.txt file4.txt and a cursor but got '%s' '%s'", got, next)
+	}
+
+	// Entries added or removed before the cursor don't shift the next page
+	if err := fs.DeleteFile("test_user", "test_folder", "file5.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "file0.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	file0 := folder.Files["file0.txt"]
+	folder.fileIndex.update(file0, func() { file0.CreatedAt = base.Add(-time.Hour) })
+
+	output, next, err = fs.ListFilesPage("test_user", "test_folder", "--sort-created", "asc", WithLimit(2), WithAfter(next))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if got := strings.Join(listNames(output), " "); got != "file3.txt file2.txt" || next == "" {
+		t.Fatalf("Expected file3.txt file2.txt and a cursor but got '%s' '%s'", got, next)
+	}
+
+	output, next, err = fs.ListFilesPage("test_user", "test_folder", "--sort-created", "asc", WithLimit(2), WithAfter(next))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if got := strings.Join(listNames(output), " "); got != "file1.txt" || next != "" {
+		t.Errorf("Expected file1.txt and no cursor but got '%s' '%s'", got, next)
+	}
+
+	// Test using a cursor with another sort order
+	_, next, _ = fs.ListFilesPage("test_user", "test_folder", "--sort-created", "asc", WithLimit(1))
+	_, _, err = fs.ListFilesPage("test_user", "test_folder", "--sort-name", "asc", WithAfter(next))
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test using a malformed cursor
+	_, _, err = fs.ListFilesPage("test_user", "test_folder", "", "", WithAfter("not a cursor"))
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestListFoldersPage(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, foldername := range []string{"a", "b", "c", "d"} {
+		if err := fs.CreateFolder("test_user", foldername, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+
+	var pages []string
+	next := ""
+	for {
+		opts := []ListOption{WithLimit(3)}
+		if next != "" {
+			opts = append(opts, WithAfter(next))
+		}
+		output, cursor, err := fs.ListFoldersPage("test_user", "--sort-name", "desc", opts...)
+		if err != nil {
+			t.Fatalf("Expected no error but got '%s'", err)
+		}
+		pages = append(pages, strings.Join(listNames(output), " "))
+		if cursor == "" {
+			break
+		}
+		next = cursor
+	}
+	if got := strings.Join(pages, " | "); got != "d c b | a" {
+		t.Errorf("Expected 'd c b | a' but got '%s'", got)
+	}
+
+	// Paging past the last entry
+	if err := fs.DeleteFolder("test_user", "a"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+	_, _, err := fs.ListFoldersPage("test_user", "--sort-name", "desc", WithAfter(next))
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Error classes of the failed calls in the logs
+const (
+	ErrorClassNotFound      = "not_found"
+	ErrorClassAlreadyExists = "already_exists"
+	ErrorClassInvalidName   = "invalid_name"
+	ErrorClassQuota         = "quota"
+	ErrorClassConflict      = "conflict"
+	ErrorClassTransaction   = "transaction"
+	ErrorClassIO            = "io"
+	ErrorClassWarning       = "warning"
+	ErrorClassInvalid       = "invalid"
+)
+
+// WithLogger makes the FileSystem log each call with its operation, user, folder, file,
+// duration and, when it fails, error class. Successful calls and warnings such as empty
+// listings are logged at the info level, failures caused by the request at warn, and
+// failures to read or write the host at error. Without this option nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(fs *FileSystem) {
+		fs.logger = logger
+	}
+}
+
+// discardHandler is the handler of the default logger, which keeps library use quiet
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logCall logs a call observed by observe
+func (fs *FileSystem) logCall(call Call, duration time.Duration, err error) {
+	ctx := context.Background()
+	level, message, class := slog.LevelInfo, "call succeeded", ""
+	if err != nil {
+		class = ErrorClass(err)
+		message = "call failed"
+		switch class {
+		case ErrorClassWarning:
+		case ErrorClassIO:
+			level = slog.LevelError
+		default:
+			level = slog.LevelWarn
+		}
+	}
+	if !fs.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("operation", call.Operation)}
+	for _, field := range []struct{ key, value string }{{"user", call.User}, {"folder", call.Folder}, {"file", call.File}} {
+		if field.value != "" {
+			attrs = append(attrs, slog.String(field.key, field.value))
+		}
+	}
+	attrs = append(attrs, slog.Duration("duration", duration))
+	if err != nil {
+		attrs = append(attrs, slog.String("error_class", class), slog.String("error", err.Error()))
+	}
+	fs.logger.LogAttrs(ctx, level, message, attrs...)
+}
+
+// ErrorClass sorts an error returned by a FileSystem into one of the error classes by
+// the sentinel error it matches, ErrorClassInvalid being the default. Hooks choose the
+// class of their vetoes by wrapping a sentinel error.
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		return ErrorClassQuota
+	case errors.Is(err, ErrTransaction):
+		return ErrorClassTransaction
+	case errors.Is(err, ErrWarning):
+		return ErrorClassWarning
+	case errors.Is(err, ErrNotFound):
+		return ErrorClassNotFound
+	case errors.Is(err, ErrAlreadyExists):
+		return ErrorClassAlreadyExists
+	case errors.Is(err, ErrInvalidName):
+		return ErrorClassInvalidName
+	case errors.Is(err, ErrConflict):
+		return ErrorClassConflict
+	case errors.Is(err, ErrIO):
+		return ErrorClassIO
+	case errors.Is(err, ErrInvalid):
+		return ErrorClassInvalid
+	}
+	return ErrorClassInvalid
+}
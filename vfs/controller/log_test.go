@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	clock := NewFakeClock(time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC))
+	fs := NewFileSystem(WithLogger(logger), WithClock(clock))
+
+	// A successful call is below the warn level
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing logged but got '%s'", out.String())
+	}
+
+	if err := fs.CreateFile("test_user", "test_folder", "test_file", ""); err == nil {
+		t.Fatalf("Expected an error but got nil")
+	}
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record but got '%s'", out.String())
+	}
+	expected := map[string]any{
+		"level":       "WARN",
+		"msg":         "call failed",
+		"operation":   "create-file",
+		"user":        "test_user",
+		"folder":      "test_folder",
+		"file":        "test_file",
+		"duration":    float64(0),
+		"error_class": ErrorClassNotFound,
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("Expected %s to be %v but got %v", key, value, record[key])
+		}
+	}
+}
+
+func TestLoggerSilentByDefault(t *testing.T) {
+	fs := NewFileSystem()
+	if fs.logger.Enabled(context.Background(), slog.LevelError) {
+		t.Errorf("Expected the default logger to be disabled")
+	}
+}
+
+func TestLoggerText(t *testing.T) {
+	var out strings.Builder
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	fs := NewFileSystem(WithLogger(logger))
+
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if _, err := fs.ListFolders("test_user", "--sort-name", "asc"); err == nil {
+		t.Fatalf("Expected a warning but got nil")
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines but got '%s'", out.String())
+	}
+	if !strings.Contains(lines[0], "level=INFO msg=\"call succeeded\" operation=register user=test_user") {
+		t.Errorf("Expected the register call but got '%s'", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=INFO msg=\"call failed\" operation=list-folders user=test_user") ||
+		!strings.Contains(lines[1], "error_class=warning") {
+		t.Errorf("Expected the list-folders warning but got '%s'", lines[1])
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, foldername := range []string{"test_folder", "Test_Folder"} {
+		if err := fs.CreateFolder("test_user", foldername, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+	conflict := fs.SetCaseMode(CaseInsensitive)
+	if err := fs.Register("nobody_folders"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	_, warning := fs.ListFolders("nobody_folders", "", "")
+	_, ioErr := fs.Import("test_user", filepath.Join(t.TempDir(), "missing"), ImportSkip)
+	veto := fs.OnBefore("tag", 0, func(call Call) error {
+		return fmt.Errorf("Error: Tags are frozen: %w", ErrConflict)
+	})
+	defer veto()
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&QuotaError{Username: "a", Resource: "folders", Limit: 1}, ErrorClassQuota},
+		{ErrTxDone, ErrorClassTransaction},
+		{warning, ErrorClassWarning},
+		// Names are not mistaken for the wording of another class
+		{fs.DeleteFolder("test_user", "transaction_docs"), ErrorClassNotFound},
+		{fs.CreateFile("test_user", "conflicts", "invalid chars", ""), ErrorClassNotFound},
+		{fs.CreateFolder("test_user", "test_folder", ""), ErrorClassAlreadyExists},
+		{fs.CreateFolder("test_user", "a b", ""), ErrorClassInvalidName},
+		{conflict, ErrorClassConflict},
+		{ioErr, ErrorClassIO},
+		{fs.AddTag("test_user", "test_folder", "", "x"), ErrorClassConflict},
+		{fs.SetDefaultQuota(Quota{Folders: -1}), ErrorClassInvalid},
+		{errors.New("Error: Invalid pattern [."), ErrorClassInvalid},
+	}
+	for _, test := range tests {
+		if result := ErrorClass(test.err); result != test.expected {
+			t.Errorf("Expected %s for '%v' but got %s", test.expected, test.err, result)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"iscool/vfs/controller/validate"
+	"sort"
+)
+
+// Metadata holds the tags and key/value attributes of a folder or a file
+type Metadata struct {
+	// Tags is kept sorted and without duplicates
+	Tags       []string          `json:"tags"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// HasTag reports whether the tag is attached
+func (m *Metadata) HasTag(tag string) bool {
+	i := sort.SearchStrings(m.Tags, tag)
+	return i < len(m.Tags) && m.Tags[i] == tag
+}
+
+// AddTag attaches a tag to a folder, or to a file when filename is not empty
+func (fs *FileSystem) AddTag(username, foldername, filename, tag string) (err error) {
+	call := Call{Operation: "tag", User: username, Folder: foldername, File: filename, Args: map[string]string{"tag": tag}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("tag", username, targetPath(username, foldername, filename), call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	if tag == "" || validate.ValidateNoInvalidChars(tag) {
+		return newError(ErrInvalidName, "Error: The %s contain invalid chars.", tag)
+	}
+
+	t, err := fs.getTarget(username, foldername, filename)
+	if err != nil {
+		return err
+	}
+	if t.metadata().HasTag(tag) {
+		return nil
+	}
+
+	t.metadata().addTag(tag)
+	fs.touch(t)
+	fs.recordChange("tag "+t.path(), func() bool {
+		if !fs.hasTarget(t) || !t.metadata().HasTag(tag) {
+			return false
+		}
+		t.metadata().removeTag(tag)
+		fs.touch(t)
+		return true
+	}, func() bool {
+		if !fs.hasTarget(t) || t.metadata().HasTag(tag) {
+			return false
+		}
+		t.metadata().addTag(tag)
+		fs.touch(t)
+		return true
+	})
+	return nil
+}
+
+// RemoveTag detaches a tag from a folder, or from a file when filename is not empty
+func (fs *FileSystem) RemoveTag(username, foldername, filename, tag string) (err error) {
+	call := Call{Operation: "untag", User: username, Folder: foldername, File: filename, Args: map[string]string{"tag": tag}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("untag", username, targetPath(username, foldername, filename), call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	t, err := fs.getTarget(username, foldername, filename)
+	if err != nil {
+		return err
+	}
+	if !t.metadata().HasTag(tag) {
+		return newError(ErrNotFound, "Error: The %s is not tagged %s.", targetPath(username, foldername, filename), tag)
+	}
+
+	t.metadata().removeTag(tag)
+	fs.touch(t)
+	fs.recordChange("untag "+t.path(), func() bool {
+		if !fs.hasTarget(t) || t.metadata().HasTag(tag) {
+			return false
+		}
+		t.metadata().addTag(tag)
+		fs.touch(t)
+		return true
+	}, func() bool {
+		if !fs.hasTarget(t) || !t.metadata().HasTag(tag) {
+			return false
+		}
+		t.metadata().removeTag(tag)
+		fs.touch(t)
+		return true
+	})
+	return nil
+}
+
+// SetAttribute sets a key/value attribute on a folder, or on a file when filename is not empty.
+// An empty value removes the attribute.
+func (fs *FileSystem) SetAttribute(username, foldername, filename, key, value string) (err error) {
+	call := Call{Operation: "set-attr", User: username, Folder: foldername, File: filename, Args: map[string]string{"key": key, "value": value}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("set-attr", username, targetPath(username, foldername, filename), call.Args, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	if key == "" || validate.ValidateNoInvalidChars(key) {
+		return newError(ErrInvalidName, "Error: The %s contain invalid chars.", key)
+	}
+
+	t, err := fs.getTarget(username, foldername, filename)
+	if err != nil {
+		return err
+	}
+
+	previous := t.metadata().Attributes[key]
+	if previous == value {
+		return nil
+	}
+
+	t.metadata().setAttribute(key, value)
+	fs.touch(t)
+	fs.recordChange("set-attr "+t.path(), func() bool {
+		if !fs.hasTarget(t) || t.metadata().Attributes[key] != value {
+			return false
+		}
+		t.metadata().setAttribute(key, previous)
+		fs.touch(t)
+		return true
+	}, func() bool {
+		if !fs.hasTarget(t) || t.metadata().Attributes[key] != previous {
+			return false
+		}
+		t.metadata().setAttribute(key, value)
+		fs.touch(t)
+		return true
+	})
+	return nil
+}
+
+// GetAttribute returns an attribute of a folder, or of a file when filename is not empty
+func (fs *FileSystem) GetAttribute(username, foldername, filename, key string) (value string, err error) {
+	call := Call{Operation: "get-attr", User: username, Folder: foldername, File: filename, Args: map[string]string{"key": key}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return "", err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return "", err
+	}
+	t, err := fs.getTarget(username, foldername, filename)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := t.metadata().Attributes[key]
+	if !ok {
+		return "", newError(ErrNotFound, "Error: The %s doesn't have the %s attribute.", targetPath(username, foldername, filename), key)
+	}
+	return value, nil
+}
+
+// GetMetadata returns a copy of the tags and attributes of a folder, or of a file when
+// filename is not empty
+func (fs *FileSystem) GetMetadata(username, foldername, filename string) (metadata Metadata, err error) {
+	call := Call{Operation: "get-meta", User: username, Folder: foldername, File: filename}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return Metadata{}, err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return Metadata{}, err
+	}
+	t, err := fs.getTarget(username, foldername, filename)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	m := t.metadata()
+	metadata = Metadata{Tags: append([]string{}, m.Tags...), Attributes: make(map[string]string, len(m.Attributes))}
+	for key, value := range m.Attributes {
+		metadata.Attributes[key] = value
+	}
+	return metadata, nil
+}
+
+// addTag inserts the tag keeping the tags sorted
+func (m *Metadata) addTag(tag string) {
+	i := sort.SearchStrings(m.Tags, tag)
+	m.Tags = append(m.Tags, "")
+	copy(m.Tags[i+1:], m.Tags[i:])
+	m.Tags[i] = tag
+}
+
+// removeTag deletes an attached tag
+func (m *Metadata) removeTag(tag string) {
+	i := sort.SearchStrings(m.Tags, tag)
+	m.Tags = append(m.Tags[:i], m.Tags[i+1:]...)
+}
+
+// setAttribute sets the attribute, an empty value removes it
+func (m *Metadata) setAttribute(key, value string) {
+	if value == "" {
+		delete(m.Attributes, key)
+		return
+	}
+	if m.Attributes == nil {
+		m.Attributes = make(map[string]string)
+	}
+	m.Attributes[key] = value
+}
+
+// target is a folder, or a file when file is not nil, carrying metadata
+type target struct {
+	user   *User
+	folder *Folder
+	file   *File
+}
+
+// metadata returns the metadata of the target
+func (t target) metadata() *Metadata {
+	if t.file == nil {
+		return &t.folder.Metadata
+	}
+	return &t.file.Metadata
+}
+
+// touch marks the target as modified
+func (fs *FileSystem) touch(t target) {
+	now := fs.clock.Now()
+	if t.file == nil {
+		t.user.folderIndex.update(t.folder, func() { t.folder.ModifiedAt = now })
+		return
+	}
+	t.folder.fileIndex.update(t.file, func() { t.file.ModifiedAt = now })
+}
+
+// path returns the current path of the target
+func (t target) path() string {
+	if t.file == nil {
+		return targetPath(t.user.Name, t.folder.Name, "")
+	}
+	return targetPath(t.user.Name, t.folder.Name, t.file.Name)
+}
+
+// getTarget returns a folder, or a file when filename is not empty
+func (fs *FileSystem) getTarget(username, foldername, filename string) (target, error) {
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return target{}, newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+
+	folder := user.getFolderByName(foldername)
+	if folder == nil {
+		return target{}, newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
+	}
+
+	if filename == "" {
+		return target{user: user, folder: folder}, nil
+	}
+
+	file := folder.getFileByName(filename)
+	if file == nil {
+		return target{}, newError(ErrNotFound, "Error: The %s doesn't exist.", filename)
+	}
+	return target{user: user, folder: folder, file: file}, nil
+}
+
+// targetPath returns the path of a folder, or of a file when filename is not empty
+func targetPath(username, foldername, filename string) string {
+	if filename == "" {
+		return username + "/" + foldername
+	}
+	return username + "/" + foldername + "/" + filename
+}
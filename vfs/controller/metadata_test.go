@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	folder := fs.getUserByUsername("test_user").Folders["test_folder"]
+	file := folder.Files["test_file.txt"]
+
+	// Tags are kept sorted and unique
+	for _, tag := range []string{"urgent", "invoice", "urgent", "2023"} {
+		if err := fs.AddTag("test_user", "test_folder", "test_file.txt", tag); err != nil {
+			t.Errorf("Expected no error but got '%s'", err)
+		}
+	}
+	if want := []string{"2023", "invoice", "urgent"}; !reflect.DeepEqual(file.Tags, want) {
+		t.Errorf("Expected %v but got %v", want, file.Tags)
+	}
+
+	// Folders are tagged independently of their files
+	if err := fs.AddTag("test_user", "test_folder", "", "archive"); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	if !folder.HasTag("archive") || file.HasTag("archive") {
+		t.Errorf("Expected only the folder to be tagged archive")
+	}
+
+	if err := fs.RemoveTag("test_user", "test_folder", "test_file.txt", "invoice"); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	if want := []string{"2023", "urgent"}; !reflect.DeepEqual(file.Tags, want) {
+		t.Errorf("Expected %v but got %v", want, file.Tags)
+	}
+
+	// Test removing a tag that isn't attached
+	if err := fs.RemoveTag("test_user", "test_folder", "test_file.txt", "invoice"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test adding an invalid tag
+	if err := fs.AddTag("test_user", "test_folder", "test_file.txt", "in voice"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test tagging a file that doesn't exist
+	if err := fs.AddTag("test_user", "test_folder", "non_existent_file.txt", "urgent"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	if err := fs.SetAttribute("test_user", "test_folder", "test_file.txt", "owner", "bob smith"); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	if err := fs.SetAttribute("test_user", "test_folder", "", "owner", "alice"); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	value, err := fs.GetAttribute("test_user", "test_folder", "test_file.txt", "owner")
+	if err != nil || value != "bob smith" {
+		t.Errorf("Expected 'bob smith' but got '%s' (%v)", value, err)
+	}
+	value, err = fs.GetAttribute("test_user", "test_folder", "", "owner")
+	if err != nil || value != "alice" {
+		t.Errorf("Expected 'alice' but got '%s' (%v)", value, err)
+	}
+
+	// An empty value removes the attribute
+	if err := fs.SetAttribute("test_user", "test_folder", "test_file.txt", "owner", ""); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	if _, err := fs.GetAttribute("test_user", "test_folder", "test_file.txt", "owner"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test setting an attribute with an invalid key
+	if err := fs.SetAttribute("test_user", "test_folder", "", "own/er", "alice"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test getting an attribute of a folder that doesn't exist
+	if _, err := fs.GetAttribute("test_user", "non_existent_folder", "", "owner"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestListFilesWithTag(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	for _, filename := range []string{"file1.txt", "file2.txt", "file3.txt"} {
+		if err := fs.CreateFile("test_user", "test_folder", filename, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+	for _, filename := range []string{"file1.txt", "file3.txt"} {
+		if err := fs.AddTag("test_user", "test_folder", filename, "urgent"); err != nil {
+			t.Fatalf("Failed to tag file: %s", err)
+		}
+	}
+
+	result, err := fs.ListFiles("test_user", "test_folder", "--sort-name", "desc", WithTag("urgent"))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	files := fs.getUserByUsername("test_user").Folders["test_folder"].Files
+	expected := "file3.txt  " + files["file3.txt"].CreatedAt.Format("2006-01-02 15:04:05") + " test_folder test_user\n" +
+		"file1.txt  " + files["file1.txt"].CreatedAt.Format("2006-01-02 15:04:05") + " test_folder test_user"
+	if result != expected {
+		t.Errorf("Expected '%s' but got '%s'", expected, result)
+	}
+
+	// Test listing with a tag no file carries
+	if _, err := fs.ListFiles("test_user", "test_folder", "", "", WithTag("missing")); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	metadata, err := fs.GetMetadata("test_user", "test_folder", "test_file.txt")
+	if err != nil || len(metadata.Tags) != 0 || len(metadata.Attributes) != 0 {
+		t.Errorf("Expected no metadata but got %v (%v)", metadata, err)
+	}
+
+	for _, tag := range []string{"urgent", "draft"} {
+		if err := fs.AddTag("test_user", "test_folder", "test_file.txt", tag); err != nil {
+			t.Fatalf("Failed to add tag: %s", err)
+		}
+	}
+	if err := fs.SetAttribute("test_user", "test_folder", "test_file.txt", "owner", "alice"); err != nil {
+		t.Fatalf("Failed to set attribute: %s", err)
+	}
+	metadata, err = fs.GetMetadata("test_user", "test_folder", "test_file.txt")
+	expected := Metadata{Tags: []string{"draft", "urgent"}, Attributes: map[string]string{"owner": "alice"}}
+	if err != nil || !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected %v but got %v (%v)", expected, metadata, err)
+	}
+
+	// The copy does not change the file
+	metadata.Tags[0] = "changed"
+	if again, _ := fs.GetMetadata("test_user", "test_folder", "test_file.txt"); again.Tags[0] != "draft" {
+		t.Errorf("Expected the tags to be copied but got %v", again.Tags)
+	}
+
+	if _, err := fs.GetMetadata("test_user", "non_existent_folder", ""); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
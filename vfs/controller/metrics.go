@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"iscool/vfs/metrics"
+)
+
+// fsMetrics are the metrics of the calls to a FileSystem
+type fsMetrics struct {
+	operations *metrics.Counter
+	latency    *metrics.Histogram
+}
+
+// WithMetrics publishes to the registry the users, folders, files and bytes stored,
+// the calls by operation and result, and the latency of the calls by operation
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(fs *FileSystem) {
+		fs.metrics = &fsMetrics{
+			operations: registry.NewCounter("iscool_operations_total", "Calls to the file system by operation and result.", "operation", "result"),
+			latency:    registry.NewHistogram("iscool_operation_duration_seconds", "Latency of the calls to the file system by operation.", metrics.DefaultBuckets, "operation"),
+		}
+
+		totals := &fs.totals
+		registry.NewGaugeFunc("iscool_users", "Registered users.", func() float64 { return float64(totals.users.Load()) })
+		registry.NewGaugeFunc("iscool_folders", "Folders of every user.", func() float64 { return float64(totals.folders.Load()) })
+		registry.NewGaugeFunc("iscool_files", "Files of every user.", func() float64 { return float64(totals.files.Load()) })
+		registry.NewGaugeFunc("iscool_bytes", "Bytes stored in the files of every user.", func() float64 { return float64(totals.bytes.Load()) })
+	}
+}
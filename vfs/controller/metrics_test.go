@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"iscool/vfs/metrics"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	fs := NewFileSystem(WithMetrics(registry), WithHistory(NewHistory()))
+
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err == nil {
+		t.Fatalf("Expected an error but got nil")
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.WriteFile("test_user", "test_folder", "test_file", []byte("hello")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if _, err := fs.ReadFile("test_user", "test_folder", "test_file"); err != nil {
+		t.Fatalf("Failed to read file: %s", err)
+	}
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	for _, expected := range []string{
+		"iscool_users 1\n",
+		"iscool_folders 1\n",
+		"iscool_files 1\n",
+		"iscool_bytes 5\n",
+		`iscool_operations_total{operation="create-folder",result="ok"} 1` + "\n",
+		`iscool_operations_total{operation="create-folder",result="error"} 1` + "\n",
+		`iscool_operations_total{operation="read-file",result="ok"} 1` + "\n",
+		`iscool_operation_duration_seconds_count{operation="write-file"} 1` + "\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected '%s' in:\n%s", strings.TrimSpace(expected), out.String())
+		}
+	}
+
+	// Test the totals following an undo, and a deleted folder with its files
+	if _, err := fs.Undo(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if got := fs.totals.bytes.Load(); got != 0 {
+		t.Errorf("Expected 0 bytes but got %d", got)
+	}
+	if err := fs.DeleteFolder("test_user", "test_folder"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+	if folders, files := fs.totals.folders.Load(), fs.totals.files.Load(); folders != 0 || files != 0 {
+		t.Errorf("Expected no folders and files but got %d and %d", folders, files)
+	}
+}
@@ -1,25 +1,89 @@
 package controller
 
-import "time"
+import (
+	"iscool/vfs/audit"
+	"iscool/vfs/search"
+	"log/slog"
+	"sync"
+	"time"
+)
 
 type FileSystem struct {
+	*state
+	// tx is set on the FileSystem of a transaction, which already holds the lock
+	tx *Tx
+}
+
+// state is shared by a FileSystem and its transactions
+type state struct {
 	Users map[string]*User
+
+	// mu guards everything but the watchers and the hooks
+	mu           sync.RWMutex
+	auditLog     *audit.Log
+	defaultQuota Quota
+	policy       ValidationPolicy
+	caseMode     CaseMode
+	clock        Clock
+	timeFormat   string
+	location     *time.Location
+	index        *search.Index
+	history      *History
+	// restoring is set while a rollback puts changes back, see withinQuota
+	restoring bool
+	metrics   *fsMetrics
+	logger    *slog.Logger
+	// totals mirrors the usage of every user, so metrics read it without the lock
+	totals usageTotals
+	// hooks has its own lock, so hooks can be added while calls run
+	hooks hookRegistry
+
+	// accessMu guards the access times, which reads update under the read lock
+	accessMu sync.Mutex
+
+	watchMu  sync.Mutex
+	watchers map[*Watcher]struct{}
 }
 
 type User struct {
-	Name    string
+	Name string
+	// Folders is keyed by the names as seen by caseMode
 	Folders map[string]*Folder
+	// Quota overrides the default quota of the file system when set
+	Quota *Quota
+
+	caseMode CaseMode
+	usage    Usage
+	// folderIndex keeps the folders sorted, it must change along with Folders
+	folderIndex orderedIndex[*Folder]
 }
 
 type Folder struct {
 	Name        string
 	Description string
 	CreatedAt   time.Time
-	Files       map[string]*File
+	// ModifiedAt changes when the folder is renamed or edited, or a file is added or removed
+	ModifiedAt time.Time
+	// AccessedAt changes when the files of the folder are listed, which only
+	// takes the read lock, so it is guarded by the accessMu of the FileSystem
+	AccessedAt time.Time
+	// Files is keyed by the names as seen by caseMode
+	Files map[string]*File
+	Metadata
+
+	caseMode CaseMode
+	// fileIndex keeps the files sorted, it must change along with Files
+	fileIndex orderedIndex[*File]
 }
 
 type File struct {
 	Name        string
 	Description string
 	CreatedAt   time.Time
+	// ModifiedAt changes when the content or the description is written
+	ModifiedAt time.Time
+	// AccessedAt changes when the content is read, under accessMu like the folders'
+	AccessedAt time.Time
+	Content    []byte
+	Metadata
 }
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"iscool/vfs/controller/validate"
+	"strings"
+	"unicode"
+)
+
+// ValidationPolicy decides which names users, folders and files can have.
+// '/', whitespace, control and invisible characters are never allowed.
+type ValidationPolicy struct {
+	// AllowedChars lists the Unicode classes names can be made of, every class when empty
+	AllowedChars []*unicode.RangeTable
+	// InvalidChars are characters names cannot contain even when their class is allowed
+	InvalidChars string
+	// MaxUsername, MaxFolder and MaxFile are the maximum lengths in characters, zero means unlimited
+	MaxUsername int
+	MaxFolder   int
+	MaxFile     int
+	// ReservedNames cannot be used for users, folders or files
+	ReservedNames []string
+	// ReservedCaseSensitive matches ReservedNames exactly instead of ignoring case
+	ReservedCaseSensitive bool
+}
+
+// DefaultValidationPolicy returns the policy of a FileSystem created without WithValidationPolicy
+func DefaultValidationPolicy() ValidationPolicy {
+	return ValidationPolicy{
+		InvalidChars: validate.DefaultInvalidChars,
+		MaxUsername:  50,
+		MaxFolder:    100,
+		MaxFile:      255,
+	}
+}
+
+// WithValidationPolicy replaces the default validation policy
+func WithValidationPolicy(policy ValidationPolicy) Option {
+	return func(fs *FileSystem) {
+		fs.policy = policy
+	}
+}
+
+// entity is the kind of a name checked against the validation policy
+type entity int
+
+const (
+	entityUser entity = iota
+	entityFolder
+	entityFile
+)
+
+// validateName checks a normalized name against the validation policy
+func (fs *FileSystem) validateName(kind entity, name string) error {
+	policy := fs.policy
+	if name == "" {
+		return newError(ErrInvalidName, "Error: The name must not be empty.")
+	}
+	if validate.ValidateChars(name, policy.InvalidChars, policy.AllowedChars) {
+		return newError(ErrInvalidName, "Error: The %s contains invalid chars.", name)
+	}
+
+	label, limit := "Username", policy.MaxUsername
+	switch kind {
+	case entityFolder:
+		label, limit = "Foldername", policy.MaxFolder
+	case entityFile:
+		label, limit = "Filename", policy.MaxFile
+	}
+	if limit > 0 && validate.ValidateLength(name, limit) {
+		return newError(ErrInvalidName, "Error: %s must be under %d characters.", label, limit)
+	}
+
+	for _, reserved := range policy.ReservedNames {
+		if name == reserved || (!policy.ReservedCaseSensitive && strings.EqualFold(name, reserved)) {
+			return newError(ErrInvalidName, "Error: The %s is a reserved name.", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestValidationPolicy(t *testing.T) {
+	fs := NewFileSystem(WithValidationPolicy(ValidationPolicy{
+		AllowedChars:  []*unicode.RangeTable{unicode.Letter, unicode.Digit, unicode.Pc, unicode.Pd},
+		InvalidChars:  "-",
+		MaxUsername:   8,
+		MaxFolder:     10,
+		MaxFile:       12,
+		ReservedNames: []string{"CON", "NUL"},
+	}))
+
+	tests := []struct {
+		name    string
+		call    func() error
+		wantErr bool
+	}{
+		{"valid username", func() error { return fs.Register("user_1") }, false},
+		{"username too long", func() error { return fs.Register("user_1234") }, true},
+		{"reserved username", func() error { return fs.Register("con") }, true},
+		{"valid folder", func() error { return fs.CreateFolder("user_1", "folder", "") }, false},
+		{"folder outside the allowed classes", func() error { return fs.CreateFolder("user_1", "folder.d", "") }, true},
+		{"folder with an invalid char", func() error { return fs.CreateFolder("user_1", "my-folder", "") }, true},
+		{"folder too long", func() error { return fs.CreateFolder("user_1", "folder_1234", "") }, true},
+		{"reserved folder", func() error { return fs.CreateFolder("user_1", "Nul", "") }, true},
+		{"file longer than a folder", func() error { return fs.CreateFile("user_1", "folder", "file_1234567", "") }, false},
+		{"file too long", func() error { return fs.CreateFile("user_1", "folder", "file_12345678", "") }, true},
+		{"reserved file", func() error { return fs.CreateFile("user_1", "folder", "CON", "") }, true},
+		{"rename to a folder too long", func() error { return fs.RenameFolder("user_1", "folder", "folder_1234") }, true},
+		{"rename to a reserved folder", func() error { return fs.RenameFolder("user_1", "folder", "con") }, true},
+		{"rename to a valid folder", func() error { return fs.RenameFolder("user_1", "folder", "folder_2") }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call()
+			if (err != nil) != test.wantErr {
+				t.Errorf("Expected an error %v but got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidationPolicyDefault(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	// Renaming applies the folder limit, not the file one
+	err := fs.RenameFolder("test_user", "test_folder", strings.Repeat("a", 101))
+	if err == nil || err.Error() != "Error: Foldername must be under 100 characters." {
+		t.Errorf("Expected the folder length error but got %v", err)
+	}
+
+	// Without a limit any length is accepted
+	fs = NewFileSystem(WithValidationPolicy(ValidationPolicy{}))
+	if err := fs.Register(strings.Repeat("a", 1000)); err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	if err := fs.Register(""); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestValidationPolicySlash(t *testing.T) {
+	// A policy cannot allow '/', which separates the names in paths and ids
+	fs := NewFileSystem(WithValidationPolicy(ValidationPolicy{InvalidChars: ""}))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.Register("test/user"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.CreateFolder("test_user", "a/b", ""); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.CreateFolder("test_user", "a", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "a", "b/c", ""); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.RenameFolder("test_user", "a", "a/b"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if _, err := fs.Search("test_user", "a"); err != nil && !strings.HasPrefix(err.Error(), "Warning") {
+		t.Errorf("Expected the search to run but got %s", err)
+	}
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// ErrQuotaExceeded is matched by errors.Is for every QuotaError
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits what a user can store, a zero limit means unlimited
+type Quota struct {
+	Folders int
+	Files   int
+	Bytes   int64
+}
+
+// Usage is what a user currently stores
+type Usage struct {
+	Folders int
+	Files   int
+	Bytes   int64
+}
+
+// usageTotals is what every user stores together
+type usageTotals struct {
+	users   atomic.Int64
+	folders atomic.Int64
+	files   atomic.Int64
+	bytes   atomic.Int64
+}
+
+// adjustUsage changes what the user stores, and the totals with it
+func (fs *FileSystem) adjustUsage(user *User, folders, files int, bytes int64) {
+	user.usage.Folders += folders
+	user.usage.Files += files
+	user.usage.Bytes += bytes
+	fs.totals.folders.Add(int64(folders))
+	fs.totals.files.Add(int64(files))
+	fs.totals.bytes.Add(bytes)
+}
+
+// folderBytes sums the content of the files of the folder
+func folderBytes(folder *Folder) int64 {
+	var bytes int64
+	for _, file := range folder.Files {
+		bytes += int64(len(file.Content))
+	}
+	return bytes
+}
+
+// QuotaError is returned when a call would take a user over a quota limit
+type QuotaError struct {
+	Username string
+	Resource string
+	Limit    int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Error: The %s has reached the %s quota of %d.", e.Username, e.Resource, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) report true
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// WithDefaultQuota sets the limits of users without their own quota
+func WithDefaultQuota(quota Quota) Option {
+	return func(fs *FileSystem) {
+		fs.defaultQuota = quota
+	}
+}
+
+// validate refuses negative limits, before any hook sees them
+func (q Quota) validate() error {
+	if q.Folders < 0 || q.Files < 0 || q.Bytes < 0 {
+		return newError(ErrInvalid, "Error: Quota limits must not be negative.")
+	}
+	return nil
+}
+
+// SetDefaultQuota changes the limits of users without their own quota
+func (fs *FileSystem) SetDefaultQuota(quota Quota) (err error) {
+	call := Call{Operation: "set-default-quota", Args: quotaArgs(quota)}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("set-default-quota", "", "", call.Args, &err)
+	if err := quota.validate(); err != nil {
+		return err
+	}
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	previous := fs.defaultQuota
+	fs.defaultQuota = quota
+	fs.recordChange("set-default-quota", func() bool {
+		if fs.defaultQuota != quota {
+			return false
+		}
+		fs.defaultQuota = previous
+		return true
+	}, func() bool {
+		if fs.defaultQuota != previous {
+			return false
+		}
+		fs.defaultQuota = quota
+		return true
+	})
+	return nil
+}
+
+// SetUserQuota gives the user its own limits instead of the default ones
+func (fs *FileSystem) SetUserQuota(username string, quota Quota) (err error) {
+	call := Call{Operation: "set-quota", User: username, Args: quotaArgs(quota)}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("set-quota", "", username, call.Args, &err)
+	if err := quota.validate(); err != nil {
+		return err
+	}
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
+	}
+
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+	previous, current := user.Quota, &quota
+	user.Quota = current
+	fs.recordChange("set-quota "+username, func() bool {
+		if !fs.hasUser(user) || user.Quota != current {
+			return false
+		}
+		user.Quota = previous
+		return true
+	}, func() bool {
+		if !fs.hasUser(user) || user.Quota != previous {
+			return false
+		}
+		user.Quota = current
+		return true
+	})
+	return nil
+}
+
+// GetQuota returns the usage of the user and the limits that apply to it
+func (fs *FileSystem) GetQuota(username string) (usage Usage, quota Quota, err error) {
+	call := Call{Operation: "get-quota", User: username}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return Usage{}, Quota{}, err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return Usage{}, Quota{}, err
+	}
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return Usage{}, Quota{}, newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+	return user.usage, fs.quotaOf(user), nil
+}
+
+// DefaultQuota returns the limits of users without their own quota
+func (fs *FileSystem) DefaultQuota() Quota {
+	if err := fs.rlock(); err != nil {
+		return Quota{}
+	}
+	defer fs.runlock()
+	return fs.defaultQuota
+}
+
+// quotaOf returns the limits that apply to the user
+func (fs *FileSystem) quotaOf(user *User) Quota {
+	if user.Quota != nil {
+		return *user.Quota
+	}
+	return fs.defaultQuota
+}
+
+// checkQuota returns a QuotaError if adding the given amounts takes the user over its limits
+func (fs *FileSystem) checkQuota(user *User, folders, files int, bytes int64) error {
+	quota := fs.quotaOf(user)
+	if folders > 0 && quota.Folders > 0 && user.usage.Folders+folders > quota.Folders {
+		return &QuotaError{Username: user.Name, Resource: "folders", Limit: int64(quota.Folders)}
+	}
+	if files > 0 && quota.Files > 0 && user.usage.Files+files > quota.Files {
+		return &QuotaError{Username: user.Name, Resource: "files", Limit: int64(quota.Files)}
+	}
+	if bytes > 0 && quota.Bytes > 0 && user.usage.Bytes+bytes > quota.Bytes {
+		return &QuotaError{Username: user.Name, Resource: "bytes", Limit: quota.Bytes}
+	}
+	return nil
+}
+
+// withinQuota reports whether undoing or redoing a change may add the usage. A change put
+// back by a rollback always may, it only restores what was there.
+func (fs *FileSystem) withinQuota(user *User, folders, files int, bytes int64) bool {
+	return fs.restoring || fs.checkQuota(user, folders, files, bytes) == nil
+}
+
+// quotaArgs returns the limits as audit arguments
+func quotaArgs(quota Quota) map[string]string {
+	return map[string]string{
+		"folders": strconv.Itoa(quota.Folders),
+		"files":   strconv.Itoa(quota.Files),
+		"bytes":   strconv.FormatInt(quota.Bytes, 10),
+	}
+}
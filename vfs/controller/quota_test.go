@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	fs := NewFileSystem(WithDefaultQuota(Quota{Folders: 1, Files: 2, Bytes: 10}))
+
+	// Test getting the quota of a user that doesn't exist
+	_, _, err := fs.GetQuota("test_user")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	// Test exceeding the folder quota
+	err = fs.CreateFolder("test_user", "test_folder2", "")
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected a quota error but got '%v'", err)
+	}
+
+	// Test exceeding the file quota
+	for _, filename := range []string{"file1.txt", "file2.txt"} {
+		if err := fs.CreateFile("test_user", "test_folder", filename, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+	err = fs.CreateFile("test_user", "test_folder", "file3.txt", "")
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected a quota error but got '%v'", err)
+	}
+
+	// Test exceeding the byte quota, overwriting a file only counts the difference
+	if err := fs.WriteFile("test_user", "test_folder", "file1.txt", []byte("12345678")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := fs.WriteFile("test_user", "test_folder", "file1.txt", []byte("1234567890")); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	err = fs.WriteFile("test_user", "test_folder", "file2.txt", []byte("1"))
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Resource != "bytes" || quotaErr.Limit != 10 {
+		t.Errorf("Expected a bytes quota error but got '%v'", err)
+	}
+
+	usage, quota, err := fs.GetQuota("test_user")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if usage != (Usage{Folders: 1, Files: 2, Bytes: 10}) {
+		t.Errorf("Expected usage of 1 folder, 2 files and 10 bytes but got %+v", usage)
+	}
+	if quota != (Quota{Folders: 1, Files: 2, Bytes: 10}) {
+		t.Errorf("Expected the default quota but got %+v", quota)
+	}
+
+	// Test raising the limits of the user only
+	if err := fs.SetUserQuota("test_user", Quota{Folders: 2}); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder2", ""); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	if err := fs.SetUserQuota("test_user", Quota{Files: -1}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected an invalid argument error but got '%v'", err)
+	}
+	if err := fs.SetDefaultQuota(Quota{Bytes: -1}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected an invalid argument error but got '%v'", err)
+	}
+
+	// Test deleting a folder releases its files and bytes
+	if err := fs.DeleteFolder("test_user", "test_folder"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+	usage, _, _ = fs.GetQuota("test_user")
+	if usage != (Usage{Folders: 1}) {
+		t.Errorf("Expected usage of 1 folder but got %+v", usage)
+	}
+
+	// Test changing the default quota
+	if err := fs.Register("test_user2"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.SetDefaultQuota(Quota{}); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	for _, foldername := range []string{"folder1", "folder2", "folder3"} {
+		if err := fs.CreateFolder("test_user2", foldername, ""); err != nil {
+			t.Errorf("Expected no error but got '%s'", err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Search finds the folders and files of the user, or of every user when username is empty,
+// whose name, description or content contain every word and "quoted phrase" of the query.
+// Matches are listed best first in the same format as ListFolders and ListFiles.
+func (fs *FileSystem) Search(username string, query string) (listing string, err error) {
+	call := Call{Operation: "search", User: username, Args: map[string]string{"query": query}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return "", err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return "", err
+	}
+	prefix := ""
+	if username != "" {
+		user := fs.getUserByUsername(username)
+		if user == nil {
+			return "", newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+		}
+		prefix = userKey(user.Name) + "/"
+	}
+
+	results, err := fs.index.Search(query, prefix)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", newError(ErrWarning, "Warning: No folders or files match %s.", query)
+	}
+
+	var output []string
+	for _, result := range results {
+		parts := strings.SplitN(result.ID, "/", 3)
+		user := fs.Users[parts[0]]
+		folder := user.getFolderByName(parts[1])
+		if len(parts) == 2 {
+			output = append(output, fmt.Sprintf("%s %s %s %s", folder.Name, folder.Description, fs.formatTime(folder.CreatedAt), user.Name))
+			continue
+		}
+		file := folder.getFileByName(parts[2])
+		output = append(output, fmt.Sprintf("%s %s %s %s %s", file.Name, file.Description, fs.formatTime(file.CreatedAt), folder.Name, user.Name))
+	}
+	return strings.Join(output, "\n"), nil
+}
+
+// indexFolder updates the search index with the name and description of the folder
+func (fs *FileSystem) indexFolder(user *User, folder *Folder) {
+	fs.index.Add(documentID(user, folder, nil), folder.Name+" "+folder.Description)
+}
+
+// indexFile updates the search index with the name, description and content of the file
+func (fs *FileSystem) indexFile(user *User, folder *Folder, file *File) {
+	fs.index.Add(documentID(user, folder, file), file.Name+" "+file.Description+" "+string(file.Content))
+}
+
+// unindexFolder removes the folder and its files from the search index
+func (fs *FileSystem) unindexFolder(user *User, folder *Folder) {
+	for _, file := range folder.Files {
+		fs.index.Remove(documentID(user, folder, file))
+	}
+	fs.index.Remove(documentID(user, folder, nil))
+}
+
+// documentID identifies a folder, or a file when file is not nil, in the search index.
+// The user part is the key of the user in FileSystem.Users so that it can be resolved back.
+func documentID(user *User, folder *Folder, file *File) string {
+	id := userKey(user.Name) + "/" + folder.Name
+	if file != nil {
+		id += "/" + file.Name
+	}
+	return id
+}
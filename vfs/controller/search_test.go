@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	fs := NewFileSystem()
+	for _, username := range []string{"test_user", "test_user2"} {
+		if err := fs.Register(username); err != nil {
+			t.Fatalf("Failed to register user: %s", err)
+		}
+		if err := fs.CreateFolder(username, "test_folder", "accounting"); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+		if err := fs.CreateFile(username, "test_folder", "a.txt", "invoice 2023"); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "b.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	// Test searching for a user that doesn't exist
+	_, err := fs.Search("non_existent_user", "invoice")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Search is scoped to the user
+	result, err := fs.Search("test_user", "invoice")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if !strings.HasPrefix(result, "a.txt invoice 2023 ") || strings.Count(result, "\n") != 0 {
+		t.Errorf("Expected only a.txt but got '%s'", result)
+	}
+
+	// Every user is searched when the username is empty
+	result, err = fs.Search("", "invoice")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if strings.Count(result, "\n") != 1 {
+		t.Errorf("Expected two results but got '%s'", result)
+	}
+
+	// Content writes are indexed
+	if err := fs.WriteFile("test_user", "test_folder", "b.txt", []byte("the invoice for 2023 is paid")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	result, err = fs.Search("test_user", "paid")
+	if err != nil || !strings.HasPrefix(result, "b.txt") {
+		t.Errorf("Expected b.txt but got '%s' (%v)", result, err)
+	}
+
+	// Renamed folders are found under their new name
+	if err := fs.RenameFolder("test_user", "test_folder", "archive"); err != nil {
+		t.Fatalf("Failed to rename folder: %s", err)
+	}
+	result, err = fs.Search("test_user", "archive")
+	if err != nil || !strings.HasPrefix(result, "archive accounting") {
+		t.Errorf("Expected the archive folder but got '%s' (%v)", result, err)
+	}
+	result, err = fs.Search("test_user", "paid")
+	if err != nil || !strings.HasSuffix(result, "archive test_user") {
+		t.Errorf("Expected b.txt in archive but got '%s' (%v)", result, err)
+	}
+
+	// Description edits are indexed
+	if err := fs.SetFolderDescription("test_user", "archive", "old taxes"); err != nil {
+		t.Fatalf("Failed to set the description: %s", err)
+	}
+	if _, err := fs.Search("test_user", "accounting"); err == nil {
+		t.Errorf("Expected the old description not to match")
+	}
+
+	// Deleted files and folders are no longer found
+	if err := fs.DeleteFile("test_user", "archive", "b.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	if _, err := fs.Search("test_user", "paid"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.DeleteFolder("test_user", "archive"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+	if _, err := fs.Search("test_user", "invoice"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if fs.index.Len() != 2 {
+		t.Errorf("Expected only the documents of test_user2 to remain but got %d", fs.index.Len())
+	}
+}
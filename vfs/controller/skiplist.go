@@ -0,0 +1,163 @@
+package controller
+
+// maxLevel bounds the height of a skip list, enough for well over a billion entries
+const maxLevel = 24
+
+type skipNode[T any] struct {
+	key   sortKey
+	value T
+	// prev links the bottom level backwards so that lists can be walked in descending order
+	prev *skipNode[T]
+	next []*skipNode[T]
+}
+
+// skipList keeps values ordered by a unique sortKey. Its zero value is an empty list.
+type skipList[T any] struct {
+	head  skipNode[T]
+	level int
+	len   int
+	tail  *skipNode[T]
+	seed  uint64
+}
+
+func (l *skipList[T]) init() {
+	if l.head.next == nil {
+		l.head.next = make([]*skipNode[T], maxLevel)
+		l.level = 1
+		l.seed = 0x9E3779B97F4A7C15
+	}
+}
+
+// randomLevel returns the height of a new node, each level being four times rarer than the one below
+func (l *skipList[T]) randomLevel() int {
+	// xorshift64, no need for math/rand to flip coins
+	l.seed ^= l.seed << 13
+	l.seed ^= l.seed >> 7
+	l.seed ^= l.seed << 17
+
+	level := 1
+	for bits := l.seed; level < maxLevel && bits&3 == 0; bits >>= 2 {
+		level++
+	}
+	return level
+}
+
+// findPath fills update with the last node before key at every level and returns the node at key, if any
+func (l *skipList[T]) findPath(key sortKey, update []*skipNode[T]) *skipNode[T] {
+	x := &l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key.before(key) {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	if x.next[0] != nil && x.next[0].key == key {
+		return x.next[0]
+	}
+	return nil
+}
+
+// insert adds the value at key, replacing the value already there
+func (l *skipList[T]) insert(key sortKey, value T) {
+	l.init()
+	var update [maxLevel]*skipNode[T]
+	if n := l.findPath(key, update[:]); n != nil {
+		n.value = value
+		return
+	}
+
+	level := l.randomLevel()
+	for i := l.level; i < level; i++ {
+		update[i] = &l.head
+	}
+	if level > l.level {
+		l.level = level
+	}
+
+	n := &skipNode[T]{key: key, value: value, next: make([]*skipNode[T], level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+	if update[0] != &l.head {
+		n.prev = update[0]
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n
+	} else {
+		l.tail = n
+	}
+	l.len++
+}
+
+// remove deletes the value at key and reports whether there was one
+func (l *skipList[T]) remove(key sortKey) bool {
+	if l.head.next == nil {
+		return false
+	}
+	var update [maxLevel]*skipNode[T]
+	n := l.findPath(key, update[:])
+	if n == nil {
+		return false
+	}
+
+	for i := 0; i < len(n.next); i++ {
+		update[i].next[i] = n.next[i]
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n.prev
+	} else {
+		l.tail = n.prev
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.len--
+	return true
+}
+
+// first returns the node with the smallest key
+func (l *skipList[T]) first() *skipNode[T] {
+	if l.head.next == nil {
+		return nil
+	}
+	return l.head.next[0]
+}
+
+// last returns the node with the largest key
+func (l *skipList[T]) last() *skipNode[T] {
+	return l.tail
+}
+
+// after returns the first node whose key comes after key
+func (l *skipList[T]) after(key sortKey) *skipNode[T] {
+	if l.head.next == nil {
+		return nil
+	}
+	x := &l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && !key.before(x.next[i].key) {
+			x = x.next[i]
+		}
+	}
+	return x.next[0]
+}
+
+// before returns the last node whose key comes before key
+func (l *skipList[T]) before(key sortKey) *skipNode[T] {
+	if l.head.next == nil {
+		return nil
+	}
+	x := &l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key.before(key) {
+			x = x.next[i]
+		}
+	}
+	if x == &l.head {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// collect walks the list in ascending or descending order
+func collect(l *skipList[int], desc bool) []int {
+	var values []int
+	n := l.first()
+	if desc {
+		n = l.last()
+	}
+	for ; n != nil; n = step(n, desc) {
+		values = append(values, n.value)
+	}
+	return values
+}
+
+func TestSkipList(t *testing.T) {
+	var l skipList[int]
+
+	if l.first() != nil || l.last() != nil || l.remove(sortKey{}) {
+		t.Fatalf("Expected the zero value to be an empty list")
+	}
+
+	// Insert shuffled keys along with a reference set
+	rng := rand.New(rand.NewSource(1))
+	present := make(map[int]bool)
+	for _, i := range rng.Perm(1000) {
+		l.insert(sortKey{time: int64(i / 10), name: fmt.Sprintf("%03d", i)}, i)
+		present[i] = true
+	}
+	// Remove a third of them
+	for i := 0; i < 1000; i += 3 {
+		if !l.remove(sortKey{time: int64(i / 10), name: fmt.Sprintf("%03d", i)}) {
+			t.Errorf("Expected %d to be removed", i)
+		}
+		delete(present, i)
+	}
+	// Inserting an existing key replaces its value
+	l.insert(sortKey{time: 0, name: "001"}, 1)
+
+	var expected []int
+	for i := range present {
+		expected = append(expected, i)
+	}
+	sort.Ints(expected)
+
+	if l.len != len(expected) {
+		t.Errorf("Expected %d entries but got %d", len(expected), l.len)
+	}
+	ascending := collect(&l, false)
+	if fmt.Sprint(ascending) != fmt.Sprint(expected) {
+		t.Errorf("Expected ascending order %v but got %v", expected, ascending)
+	}
+	descending := collect(&l, true)
+	sort.Sort(sort.Reverse(sort.IntSlice(expected)))
+	if fmt.Sprint(descending) != fmt.Sprint(expected) {
+		t.Errorf("Expected descending order %v but got %v", expected, descending)
+	}
+
+	// Seek around a removed key
+	key := sortKey{time: 30, name: "300"}
+	if n := l.after(key); n == nil || n.value != 301 {
+		t.Errorf("Expected 301 after 300 but got %v", n)
+	}
+	if n := l.before(key); n == nil || n.value != 299 {
+		t.Errorf("Expected 299 before 300 but got %v", n)
+	}
+	if n := l.before(sortKey{name: "000"}); n != nil {
+		t.Errorf("Expected nothing before the first key but got %v", n.value)
+	}
+	if n := l.after(sortKey{time: 99, name: "999"}); n != nil {
+		t.Errorf("Expected nothing after the last key but got %v", n.value)
+	}
+}
+
+// newBenchmarkFolder returns a folder of n files created one second apart, in random name order
+func newBenchmarkFolder(n int) *Folder {
+	folder := &Folder{Name: "bench", Files: make(map[string]*File, n)}
+	base := time.Now()
+	for i, j := range rand.New(rand.NewSource(1)).Perm(n) {
+		file := &File{
+			Name:       fmt.Sprintf("file%07d.txt", j),
+			CreatedAt:  base.Add(time.Duration(i) * time.Second),
+			ModifiedAt: base.Add(time.Duration(i) * time.Second),
+		}
+		folder.Files[file.Name] = file
+		folder.fileIndex.insert(file)
+	}
+	return folder
+}
+
+// sortedPage is how listings used to work: copy the map into a slice, sort it, then cut a page
+func sortedPage(folder *Folder, limit int) []*File {
+	fileInfo := make([]*File, 0, len(folder.Files))
+	for _, file := range folder.Files {
+		fileInfo = append(fileInfo, file)
+	}
+	sort.Slice(fileInfo, func(i, j int) bool {
+		return fileInfo[i].CreatedAt.After(fileInfo[j].CreatedAt)
+	})
+	return fileInfo[:limit]
+}
+
+func BenchmarkListFilesFirstPage(b *testing.B) {
+	for _, n := range []int{1000, 100000, 1000000} {
+		folder := newBenchmarkFolder(n)
+		options := listOptions{limit: 50}
+
+		b.Run(fmt.Sprintf("sorted/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sortedPage(folder, options.limit)
+			}
+		})
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := page(&folder.fileIndex, "--sort-created", "desc", options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListFilesLaterPage(b *testing.B) {
+	folder := newBenchmarkFolder(1000000)
+	_, cursor, _ := page(&folder.fileIndex, "--sort-name", "asc", listOptions{limit: 500000})
+	options := listOptions{limit: 50, after: cursor}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := page(&folder.fileIndex, "--sort-name", "asc", options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyncStateName is the file of a synced host directory remembering the files as they were
+// after the last sync, so the next one can tell which side changed and skip the others
+const SyncStateName = ".iscool-sync.json"
+
+// SyncMode decides which side of a sync is written
+type SyncMode int
+
+const (
+	// SyncBoth copies the changes of each side to the other
+	SyncBoth SyncMode = iota
+	// SyncPull copies the changes of the host directory to the folder
+	SyncPull
+	// SyncPush copies the changes of the folder to the host directory
+	SyncPush
+)
+
+// String returns the name of the sync mode
+func (m SyncMode) String() string {
+	switch m {
+	case SyncBoth:
+		return "both"
+	case SyncPull:
+		return "pull"
+	case SyncPush:
+		return "push"
+	}
+	return "unknown"
+}
+
+// ParseSyncMode returns the sync mode with the given name
+func ParseSyncMode(name string) (SyncMode, error) {
+	for _, m := range []SyncMode{SyncBoth, SyncPull, SyncPush} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return SyncBoth, fmt.Errorf("Error: Unknown sync mode %s. Valid modes are 'both' 'pull' 'push'", name)
+}
+
+// SyncAction is what a sync does with a file
+type SyncAction int
+
+const (
+	// SyncToFolder copies the host file to the folder
+	SyncToFolder SyncAction = iota
+	// SyncToHost copies the file of the folder to the host directory
+	SyncToHost
+	// SyncDeleteInFolder deletes the file of the folder, it was deleted on the host
+	SyncDeleteInFolder
+	// SyncDeleteOnHost deletes the host file, it was deleted in the folder
+	SyncDeleteOnHost
+	// SyncConflict leaves a file changed on both sides alone
+	SyncConflict
+	// SyncSkip leaves a file that cannot be synced alone
+	SyncSkip
+)
+
+// String returns the name of the sync action
+func (a SyncAction) String() string {
+	switch a {
+	case SyncToFolder:
+		return "pull"
+	case SyncToHost:
+		return "push"
+	case SyncDeleteInFolder:
+		return "delete-in-folder"
+	case SyncDeleteOnHost:
+		return "delete-on-host"
+	case SyncConflict:
+		return "conflict"
+	case SyncSkip:
+		return "skip"
+	}
+	return "unknown"
+}
+
+// SyncChange is what a sync does, or would do on a dry run, with a file
+type SyncChange struct {
+	Name   string
+	Action SyncAction
+	Reason string
+}
+
+// String formats the change for display
+func (c SyncChange) String() string {
+	return fmt.Sprintf("%s %s (%s)", c.Action, c.Name, c.Reason)
+}
+
+// SyncReport lists the changes of a sync, sorted by file name
+type SyncReport struct {
+	Changes []SyncChange
+}
+
+// Conflicts counts the files changed on both sides
+func (r *SyncReport) Conflicts() int {
+	count := 0
+	for _, c := range r.Changes {
+		if c.Action == SyncConflict {
+			count++
+		}
+	}
+	return count
+}
+
+// Sync compares the files of a folder with the files of a host directory by name, size and
+// hash, and copies or deletes files so that both sides match. The state saved in the
+// directory tells which side changed since the last sync; a file changed on both sides is
+// a conflict and is left alone. SyncPull and SyncPush only write one side, and a dry run
+// reports the changes without making them. Subdirectories of the host are not synced.
+// The changes to the folder apply completely or not at all, and are undone as a whole.
+func (fs *FileSystem) Sync(username, foldername, dir string, mode SyncMode, dryRun bool) (report *SyncReport, err error) {
+	call := Call{Operation: "sync", User: username, Folder: foldername, Args: map[string]string{"dir": dir, "mode": mode.String(), "dry_run": strconv.FormatBool(dryRun)}}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.beforeUnlocked(call, !dryRun); err != nil {
+		return nil, err
+	}
+	state, err := loadSyncState(dir)
+	if err != nil {
+		return nil, newError(ErrIO, "Error: Cannot sync %s: %v", dir, err)
+	}
+	host, skipped, err := readSyncDir(dir, mode)
+	if err != nil {
+		return nil, newError(ErrIO, "Error: Cannot sync %s: %v", dir, err)
+	}
+
+	var plan *syncPlan
+	if dryRun {
+		if err := fs.rlock(); err != nil {
+			return nil, err
+		}
+		defer fs.runlock()
+		if plan, err = fs.planSync(username, foldername, host, state, mode); err != nil {
+			return nil, err
+		}
+		return plan.report(skipped), nil
+	}
+
+	err = fs.atomically(func(run *FileSystem) error {
+		if plan, err = run.planSync(username, foldername, host, state, mode); err != nil {
+			return err
+		}
+		return run.applySyncToFolder(plan)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report = plan.report(skipped)
+	if err := applySyncToHost(dir, plan); err != nil {
+		return nil, newError(ErrIO, "Error: Cannot sync %s: %v", dir, err)
+	}
+	state.Folders[plan.key] = plan.records
+	if err := saveSyncState(dir, state); err != nil {
+		return nil, newError(ErrIO, "Error: Cannot sync %s: %v", dir, err)
+	}
+	return report, nil
+}
+
+// syncFile is a file of one side of a sync. The content of a host file is read by digest.
+type syncFile struct {
+	size    int64
+	modTime time.Time
+	path    string
+	content []byte
+	hash    string
+}
+
+func (f *syncFile) read() ([]byte, error) {
+	if f.content == nil && f.path != "" {
+		content, err := os.ReadFile(f.path)
+		if err != nil {
+			return nil, err
+		}
+		f.content = content
+	}
+	return f.content, nil
+}
+
+func (f *syncFile) digest() (string, error) {
+	if f.hash == "" {
+		content, err := f.read()
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(content)
+		f.hash = hex.EncodeToString(sum[:])
+	}
+	return f.hash, nil
+}
+
+// syncRecord is a file as both sides had it after a sync
+type syncRecord struct {
+	Size          int64     `json:"size"`
+	Hash          string    `json:"hash"`
+	HostModTime   time.Time `json:"host_mod_time"`
+	FolderModTime time.Time `json:"folder_mod_time"`
+}
+
+// syncState is kept in the host directory, keyed by username/foldername and file name
+type syncState struct {
+	Folders map[string]map[string]syncRecord `json:"folders"`
+}
+
+func loadSyncState(dir string) (*syncState, error) {
+	state := &syncState{}
+	data, err := os.ReadFile(filepath.Join(dir, SyncStateName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("invalid sync state: %v", err)
+		}
+	}
+	if state.Folders == nil {
+		state.Folders = make(map[string]map[string]syncRecord)
+	}
+	return state, nil
+}
+
+func saveSyncState(dir string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, SyncStateName), data, 0644)
+}
+
+// readSyncDir lists and hashes the regular files of the host directory, so that the sync
+// reads nothing from the host while it holds the lock. A missing directory is empty,
+// it is created when the sync writes to it.
+func readSyncDir(dir string, mode SyncMode) (map[string]*syncFile, []SyncChange, error) {
+	files := make(map[string]*syncFile)
+	var skipped []SyncChange
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) && mode != SyncPull {
+		return files, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == SyncStateName {
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			skipped = append(skipped, SyncChange{Name: name, Action: SyncSkip, Reason: "only regular files are synced"})
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, nil, err
+		}
+		file := &syncFile{size: info.Size(), modTime: info.ModTime(), path: filepath.Join(dir, name)}
+		if _, err := file.digest(); err != nil {
+			return nil, nil, err
+		}
+		files[name] = file
+	}
+	return files, skipped, nil
+}
+
+// syncStep is a change of the plan with the files of both sides
+type syncStep struct {
+	change SyncChange
+	folder *syncFile
+	host   *syncFile
+}
+
+// syncPlan is what a sync does, and the records it leaves once done
+type syncPlan struct {
+	key      string
+	user     *User
+	folder   *Folder
+	steps    []syncStep
+	records  map[string]syncRecord
+	username string
+}
+
+func (p *syncPlan) report(skipped []SyncChange) *SyncReport {
+	report := &SyncReport{}
+	for _, step := range p.steps {
+		report.Changes = append(report.Changes, step.change)
+	}
+	report.Changes = append(report.Changes, skipped...)
+	sort.SliceStable(report.Changes, func(i, j int) bool { return report.Changes[i].Name < report.Changes[j].Name })
+	return report
+}
+
+// planSync decides what to do with each file, the lock must be held
+func (fs *FileSystem) planSync(username, foldername string, host map[string]*syncFile, state *syncState, mode SyncMode) (*syncPlan, error) {
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil, newError(ErrNotFound, "Error: %s doesn't exist.", username)
+	}
+	folder := user.getFolderByName(foldername)
+	if folder == nil {
+		return nil, newError(ErrNotFound, "Error: %s doesn't exist.", foldername)
+	}
+
+	local := make(map[string]*syncFile, len(folder.Files))
+	for _, file := range folder.Files {
+		sum := sha256.Sum256(file.Content)
+		local[file.Name] = &syncFile{
+			size:    int64(len(file.Content)),
+			modTime: file.ModifiedAt,
+			content: file.Content,
+			hash:    hex.EncodeToString(sum[:]),
+		}
+	}
+
+	plan := &syncPlan{
+		key:      user.Name + "/" + folder.Name,
+		user:     user,
+		folder:   folder,
+		records:  make(map[string]syncRecord),
+		username: username,
+	}
+	base := state.Folders[plan.key]
+
+	names := make(map[string]struct{})
+	for name := range local {
+		names[name] = struct{}{}
+	}
+	for name := range host {
+		names[name] = struct{}{}
+	}
+	for name := range base {
+		names[name] = struct{}{}
+	}
+
+	for name := range names {
+		if err := fs.planSyncFile(plan, name, local[name], host[name], base, mode); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// planSyncFile adds the step of a file to the plan, along with the record it leaves
+func (fs *FileSystem) planSyncFile(plan *syncPlan, name string, local, host *syncFile, base map[string]syncRecord, mode SyncMode) error {
+	record, synced := base[name]
+	localChanged, err := changedSince(local, record, synced, record.FolderModTime)
+	if err != nil {
+		return err
+	}
+	hostChanged, err := changedSince(host, record, synced, record.HostModTime)
+	if err != nil {
+		return err
+	}
+
+	if !localChanged && !hostChanged {
+		// Refresh the modification times so the files are not read next time
+		plan.records[name] = syncRecord{Size: record.Size, Hash: record.Hash, HostModTime: host.modTime, FolderModTime: local.modTime}
+		return nil
+	}
+	if localChanged && hostChanged {
+		same, err := sameContent(local, host)
+		if err != nil {
+			return err
+		}
+		switch {
+		case same && local != nil:
+			plan.records[name] = syncRecord{Size: local.size, Hash: local.hash, HostModTime: host.modTime, FolderModTime: local.modTime}
+		case !same:
+			plan.steps = append(plan.steps, syncStep{change: SyncChange{Name: name, Action: SyncConflict, Reason: "changed on both sides"}})
+			if synced {
+				plan.records[name] = record
+			}
+		}
+		return nil
+	}
+
+	if synced {
+		plan.records[name] = record
+	}
+	step := syncStep{change: SyncChange{Name: name}, folder: local, host: host}
+	if localChanged {
+		if mode == SyncPull {
+			return nil
+		}
+		step.change.Action, step.change.Reason = SyncToHost, changeReason(local, synced)
+		if local == nil {
+			step.change.Action = SyncDeleteOnHost
+		} else if name == "." || name == ".." || name == SyncStateName {
+			step.change.Action, step.change.Reason = SyncSkip, "not a valid host file name"
+		}
+	} else {
+		if mode == SyncPush {
+			return nil
+		}
+		step.change.Action, step.change.Reason = SyncToFolder, changeReason(host, synced)
+		if host == nil {
+			step.change.Action = SyncDeleteInFolder
+		} else if local == nil {
+			if err := fs.checkSyncName(plan.folder, name); err != nil {
+				step.change.Action, step.change.Reason = SyncSkip, strings.TrimSuffix(strings.TrimPrefix(err.Error(), "Error: "), ".")
+			}
+		}
+	}
+	plan.steps = append(plan.steps, step)
+	return nil
+}
+
+// checkSyncName reports why a host file cannot be created in the folder
+func (fs *FileSystem) checkSyncName(folder *Folder, name string) error {
+	if err := fs.validateName(entityFile, folder.caseMode.name(name)); err != nil {
+		return err
+	}
+	if folder.isFileExists(name) {
+		return newError(ErrAlreadyExists, "Error: The %s has already existed.", name)
+	}
+	return nil
+}
+
+// changedSince reports whether a side of a file differs from the record of the last sync.
+// A file whose size and modification time are unchanged is not compared by hash.
+func changedSince(file *syncFile, record syncRecord, synced bool, modTime time.Time) (bool, error) {
+	if file == nil || !synced {
+		return (file == nil) == synced, nil
+	}
+	if file.size != record.Size {
+		return true, nil
+	}
+	if file.modTime.Equal(modTime) {
+		return false, nil
+	}
+	hash, err := file.digest()
+	return hash != record.Hash, err
+}
+
+// sameContent reports whether both sides hold the same file, or both lack it
+func sameContent(local, host *syncFile) (bool, error) {
+	if local == nil || host == nil {
+		return local == host, nil
+	}
+	if local.size != host.size {
+		return false, nil
+	}
+	hash, err := host.digest()
+	return hash == local.hash, err
+}
+
+func changeReason(file *syncFile, synced bool) string {
+	switch {
+	case file == nil:
+		return "deleted"
+	case synced:
+		return "changed"
+	}
+	return "new"
+}
+
+// applySyncToFolder makes the changes of the plan to the folder, the lock must be held
+func (fs *FileSystem) applySyncToFolder(plan *syncPlan) error {
+	username, foldername := plan.username, plan.folder.Name
+	for _, step := range plan.steps {
+		name := step.change.Name
+		switch step.change.Action {
+		case SyncToFolder:
+			content, err := step.host.read()
+			if err != nil {
+				return newError(ErrIO, "Error: Cannot read %s: %v", step.host.path, err)
+			}
+			if step.folder == nil {
+				if err := fs.CreateFile(username, foldername, name, ""); err != nil {
+					return err
+				}
+			}
+			if err := fs.WriteFile(username, foldername, name, content); err != nil {
+				return err
+			}
+			hash, _ := step.host.digest()
+			file := plan.folder.getFileByName(name)
+			plan.records[name] = syncRecord{Size: step.host.size, Hash: hash, HostModTime: step.host.modTime, FolderModTime: file.ModifiedAt}
+		case SyncDeleteInFolder:
+			if err := fs.DeleteFile(username, foldername, name); err != nil {
+				return err
+			}
+			delete(plan.records, name)
+		}
+	}
+	return nil
+}
+
+// applySyncToHost makes the changes of the plan to the host directory
+func applySyncToHost(dir string, plan *syncPlan) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, step := range plan.steps {
+		name := step.change.Name
+		p := filepath.Join(dir, name)
+		switch step.change.Action {
+		case SyncToHost:
+			if err := os.WriteFile(p, step.folder.content, 0644); err != nil {
+				return err
+			}
+			info, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			plan.records[name] = syncRecord{Size: step.folder.size, Hash: step.folder.hash, HostModTime: info.ModTime(), FolderModTime: step.folder.modTime}
+		case SyncDeleteOnHost:
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			delete(plan.records, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSyncFileSystem returns a folder holding a.txt and a host directory holding b.txt
+func newSyncFileSystem(t *testing.T) (*FileSystem, *FakeClock, string) {
+	clock := NewFakeClock(time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC))
+	fs := NewFileSystem(WithClock(clock), WithHistory(NewHistory()))
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "docs", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "docs", "a.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.WriteFile("test_user", "docs", "a.txt", []byte("from the folder")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("from the host"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	return fs, clock, dir
+}
+
+func expectSyncChanges(t *testing.T, report *SyncReport, expected ...string) {
+	t.Helper()
+	if len(report.Changes) != len(expected) {
+		t.Fatalf("Expected %v but got %v", expected, report.Changes)
+	}
+	for i, change := range report.Changes {
+		if change.String() != expected[i] {
+			t.Errorf("Expected '%s' but got '%s'", expected[i], change.String())
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	fs, clock, dir := newSyncFileSystem(t)
+
+	// Test syncing a folder that doesn't exist
+	_, err := fs.Sync("test_user", "missing", dir, SyncBoth, false)
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// Test a dry run, which changes nothing
+	report, err := fs.Sync("test_user", "docs", dir, SyncBoth, true)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectSyncChanges(t, report, "push a.txt (new)", "pull b.txt (new)")
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the dry run not to write a.txt")
+	}
+
+	report, err = fs.Sync("test_user", "docs", dir, SyncBoth, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectSyncChanges(t, report, "push a.txt (new)", "pull b.txt (new)")
+	content, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil || string(content) != "from the folder" {
+		t.Errorf("Expected 'from the folder' but got '%s' (%v)", content, err)
+	}
+	read, err := fs.ReadFile("test_user", "docs", "b.txt")
+	if err != nil || string(read) != "from the host" {
+		t.Errorf("Expected 'from the host' but got '%s' (%v)", read, err)
+	}
+
+	// Test a sync with nothing to do
+	report, err = fs.Sync("test_user", "docs", dir, SyncBoth, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectSyncChanges(t, report)
+
+	// Test changes on each side, and on both sides of the same file
+	clock.Advance(time.Minute)
+	if err := fs.WriteFile("test_user", "docs", "a.txt", []byte("changed in the folder")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := fs.WriteFile("test_user", "docs", "b.txt", []byte("b changed in the folder")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b changed on the host"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.txt"), []byte("new on the host"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	// A pull leaves the changes of the folder for later
+	report, err = fs.Sync("test_user", "docs", dir, SyncPull, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectSyncChanges(t, report, "conflict b.txt (changed on both sides)", "pull c.txt (new)")
+
+	report, err = fs.Sync("test_user", "docs", dir, SyncBoth, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectSyncChanges(t, report, "push a.txt (changed)", "conflict b.txt (changed on both sides)")
+	if report.Conflicts() != 1 {
+		t.Errorf("Expected 1 conflict but got %d", report.Conflicts())
+	}
+
+	// Test deletions on each side
+	if err := fs.DeleteFile("test_user", "docs", "a.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "c.txt")); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	report, err = fs.Sync("test_user", "docs", dir, SyncBoth, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectSyncChanges(t, report, "delete-on-host a.txt (deleted)", "conflict b.txt (changed on both sides)", "delete-in-folder c.txt (deleted)")
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected a.txt to be deleted on the host")
+	}
+	if _, err := fs.ReadFile("test_user", "docs", "c.txt"); err == nil {
+		t.Errorf("Expected c.txt to be deleted in the folder")
+	}
+
+	// Test undoing the changes a sync made to the folder
+	if _, err := fs.Undo(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if _, err := fs.ReadFile("test_user", "docs", "c.txt"); err != nil {
+		t.Errorf("Expected c.txt to be back but got '%s'", err)
+	}
+}
+
+func TestSyncSkip(t *testing.T) {
+	fs, _, dir := newSyncFileSystem(t)
+	if err := os.WriteFile(filepath.Join(dir, "bad name"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %s", err)
+	}
+
+	report, err := fs.Sync("test_user", "docs", dir, SyncPull, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	expectSyncChanges(t, report,
+		"pull b.txt (new)",
+		"skip bad name (The bad name contains invalid chars)",
+		"skip sub (only regular files are synced)",
+	)
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected a pull not to write a.txt")
+	}
+}
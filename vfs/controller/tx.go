@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"iscool/vfs/audit"
+	"strconv"
+)
+
+// ErrTxDone is returned by the calls made on a transaction after it is committed or rolled back
+var ErrTxDone error = &classError{class: ErrTransaction, message: "Error: The transaction has already been committed or rolled back."}
+
+// Tx is a transaction started by Begin. It has every method of FileSystem and
+// its changes apply completely or not at all: a failing call rolls back every change
+// made so far. Until the transaction is done, other calls on the FileSystem wait,
+// so they never see part of it.
+type Tx struct {
+	*FileSystem
+
+	base    *FileSystem
+	changes []*change
+	events  []pendingEvent
+	// audit holds the audit entries of the calls, written when the transaction is committed
+	audit []audit.Entry
+	// calls holds the calls whose after hooks run when the transaction is committed
+	calls []pendingCall
+	done  bool
+	// failed is set when a failing call rolled the transaction back, until the
+	// rollback is recorded once the call is done
+	failed bool
+}
+
+// pendingEvent is a watch event held back until its transaction is committed
+type pendingEvent struct {
+	user   *User
+	folder *Folder
+	event  Event
+}
+
+// pendingCall is a call whose after hooks are held back until its transaction is committed
+type pendingCall struct {
+	call Call
+	err  error
+}
+
+// Begin starts a transaction, waiting for the one in progress, if any, to be done
+func (fs *FileSystem) Begin() (*Tx, error) {
+	if fs.tx != nil {
+		return nil, newError(ErrTransaction, "Error: A transaction is already in progress.")
+	}
+
+	fs.mu.Lock()
+	tx := &Tx{base: fs}
+	tx.FileSystem = &FileSystem{state: fs.state, tx: tx}
+	return tx, nil
+}
+
+// Commit applies the changes of the transaction, which can then be undone as a whole
+func (tx *Tx) Commit() (err error) {
+	call := Call{Operation: "commit", Args: map[string]string{"changes": strconv.Itoa(len(tx.changes))}}
+	// The rollback of a vetoed commit is recorded after the commit, like that of a failing call
+	defer func() {
+		if tx.failed {
+			tx.failed = false
+			tx.recordRollback()
+		}
+	}()
+	defer tx.base.observe(call, tx.base.clock.Now(), &err)
+	defer tx.base.recordAudit("commit", "", "", call.Args, &err)
+
+	if tx.done {
+		return ErrTxDone
+	}
+	// A vetoed commit rolls the transaction back, so the lock is never left held
+	if err := tx.base.before(call); err != nil {
+		tx.rollback()
+		tx.failed = true
+		return err
+	}
+	tx.done = true
+	// The after hooks run once the lock is released, like those of calls outside a transaction
+	calls := tx.calls
+	tx.calls = nil
+	defer func() {
+		for _, pending := range calls {
+			tx.base.after(pending.call, pending.err)
+		}
+	}()
+	defer tx.base.mu.Unlock()
+
+	for _, entry := range tx.audit {
+		tx.base.writeAudit(entry)
+	}
+	tx.audit = nil
+	for _, pending := range tx.events {
+		tx.base.deliver(pending.user, pending.folder, pending.event)
+	}
+	tx.events = nil
+
+	if len(tx.changes) > 0 {
+		changes := tx.changes
+		tx.base.recordChange("transaction of "+strconv.Itoa(len(changes))+" changes", func() bool {
+			return tx.base.undoChanges(changes)
+		}, func() bool {
+			return tx.base.redoChanges(changes)
+		})
+	}
+	return nil
+}
+
+// Rollback discards the changes of the transaction
+func (tx *Tx) Rollback() (err error) {
+	call := Call{Operation: "rollback", Args: map[string]string{"changes": strconv.Itoa(len(tx.changes))}}
+	defer tx.base.observe(call, tx.base.clock.Now(), &err)
+	defer tx.base.recordAudit("rollback", "", "", call.Args, &err)
+
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.rollback()
+	return nil
+}
+
+// Done reports whether the transaction was committed or rolled back, including
+// when a failing call rolled it back
+func (tx *Tx) Done() bool {
+	return tx.done
+}
+
+// atomically runs fn in a transaction, or in the one in progress when fs belongs to
+// a transaction, so the changes made by fn apply completely or not at all
+func (fs *FileSystem) atomically(fn func(run *FileSystem) error) error {
+	if fs.tx != nil {
+		return fn(fs)
+	}
+
+	tx, err := fs.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx.FileSystem)
+	if err == nil {
+		err = tx.Commit()
+	}
+	// A failing call or a vetoed commit has usually rolled the transaction back already
+	if err != nil && !tx.Done() {
+		tx.Rollback()
+	}
+	return err
+}
+
+// rollback undoes the changes in reverse order and releases the lock
+func (tx *Tx) rollback() {
+	// Nothing else ran since the changes were made, so none of them conflicts
+	tx.base.restore(func() {
+		for i := len(tx.changes) - 1; i >= 0; i-- {
+			tx.changes[i].undo()
+		}
+	})
+	tx.events = nil
+	tx.audit = nil
+	tx.calls = nil
+	tx.done = true
+	tx.base.mu.Unlock()
+}
+
+// recordRollback records the rollback caused by a failing call like an explicit one
+func (tx *Tx) recordRollback() {
+	call := Call{Operation: "rollback", Args: map[string]string{"changes": strconv.Itoa(len(tx.changes))}}
+	var err error
+	tx.base.recordAudit("rollback", "", "", call.Args, &err)
+	tx.base.observe(call, tx.base.clock.Now(), &err)
+}
+
+// undoChanges undoes the changes in reverse order. If one of them conflicts,
+// those already undone are redone and it reports false.
+func (fs *FileSystem) undoChanges(changes []*change) bool {
+	for i := len(changes) - 1; i >= 0; i-- {
+		if !changes[i].undo() {
+			fs.restore(func() {
+				for j := i + 1; j < len(changes); j++ {
+					changes[j].redo()
+				}
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// redoChanges redoes the changes in order. If one of them conflicts,
+// those already redone are undone and it reports false.
+func (fs *FileSystem) redoChanges(changes []*change) bool {
+	for i := range changes {
+		if !changes[i].redo() {
+			fs.restore(func() {
+				for j := i - 1; j >= 0; j-- {
+					changes[j].undo()
+				}
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// restore runs fn, which puts back changes that were just reverted or replayed,
+// without checking the quota
+func (fs *FileSystem) restore(fn func()) {
+	fs.restoring = true
+	defer func() { fs.restoring = false }()
+	fn()
+}
+
+// lock takes the write lock, which a transaction already holds
+func (fs *FileSystem) lock() error {
+	if fs.tx != nil {
+		if fs.tx.done {
+			return ErrTxDone
+		}
+		return nil
+	}
+	fs.mu.Lock()
+	return nil
+}
+
+// unlock releases the lock taken by lock. Inside a transaction, a call failing
+// with *err rolls the transaction back; err is nil for calls that don't change the state.
+func (fs *FileSystem) unlock(err *error) {
+	if fs.tx != nil {
+		if err != nil && *err != nil {
+			fs.tx.rollback()
+			fs.tx.failed = true
+		}
+		return
+	}
+	fs.mu.Unlock()
+}
+
+// rlock takes the read lock, which a transaction already holds
+func (fs *FileSystem) rlock() error {
+	if fs.tx != nil {
+		if fs.tx.done {
+			return ErrTxDone
+		}
+		return nil
+	}
+	fs.mu.RLock()
+	return nil
+}
+
+// runlock releases the lock taken by rlock
+func (fs *FileSystem) runlock() {
+	if fs.tx == nil {
+		fs.mu.RUnlock()
+	}
+}
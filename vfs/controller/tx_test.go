@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTxCommit(t *testing.T) {
+	fs := NewFileSystem(WithHistory(NewHistory()))
+
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if _, err := tx.Begin(); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := tx.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	w, err := tx.Watch("test_user", "")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	defer w.Close()
+	if err := tx.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := tx.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	// Events are held back until the commit
+	select {
+	case got := <-w.Events:
+		t.Errorf("Expected no event before the commit but got %+v", got)
+	default:
+	}
+
+	// Other calls wait for the transaction
+	registered := make(chan error)
+	go func() {
+		registered <- fs.Register("other_user")
+	}()
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if err := <-registered; err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if got := <-w.Events; got.Type != EventCreated || got.Folder != "test_folder" || got.File != "" {
+		t.Errorf("Expected the creation of test_folder but got %+v", got)
+	}
+	if got := <-w.Events; got.Type != EventCreated || got.File != "test_file.txt" {
+		t.Errorf("Expected the creation of test_file.txt but got %+v", got)
+	}
+
+	// The transaction is done
+	if err := tx.CreateFolder("test_user", "other_folder", ""); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Expected ErrTxDone but got %v", err)
+	}
+	if err := tx.Rollback(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Expected ErrTxDone but got %v", err)
+	}
+
+	// The transaction is undone as a whole, after the registration made since
+	if _, err := fs.Undo(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	action, err := fs.Undo()
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if action != "transaction of 3 changes" {
+		t.Errorf("Expected the transaction to be undone but got '%s'", action)
+	}
+	if len(fs.Users) != 0 {
+		t.Errorf("Expected no users but got %d", len(fs.Users))
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if err := tx.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := tx.CreateFile("test_user", "test_folder", "test_file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := tx.WriteFile("test_user", "test_folder", "test_file.txt", []byte("hello")); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+
+	if _, err := fs.ListFolders("test_user", "--sort-name", "asc"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	usage, _, _ := fs.GetQuota("test_user")
+	if usage != (Usage{}) {
+		t.Errorf("Expected no usage but got %+v", usage)
+	}
+	if _, err := fs.Search("test_user", "hello"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestTxFailure(t *testing.T) {
+	fs := NewFileSystem()
+
+	tx, err := fs.Begin()
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if err := tx.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := tx.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	// A failing call rolls every change back
+	if err := tx.CreateFolder("test_user", "invalid/folder", ""); err == nil {
+		t.Fatalf("Expected an error but got nil")
+	}
+	if !tx.Done() {
+		t.Errorf("Expected the transaction to be done")
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Expected ErrTxDone but got %v", err)
+	}
+	if len(fs.Users) != 0 {
+		t.Errorf("Expected no users but got %d", len(fs.Users))
+	}
+
+	// Reads that fail don't roll back
+	tx, err = fs.Begin()
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if err := tx.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if _, err := tx.ListFolders("test_user", "--sort-name", "asc"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if !fs.isUserExists("test_user") {
+		t.Errorf("Expected the user to be registered")
+	}
+}
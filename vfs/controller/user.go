@@ -1,34 +1,125 @@
 package controller
 
 import (
-	"fmt"
 	"iscool/vfs/controller/validate"
+	"sort"
 	"strings"
 )
 
 // Register register a new user
-func (fs *FileSystem) Register(name string) error {
-	if validate.ValidateNoInvalidChars(name) {
-		return fmt.Errorf("Error: The %s contain invalid chars.", name)
+func (fs *FileSystem) Register(name string) (err error) {
+	call := Call{Operation: "register", User: name}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	defer fs.recordAudit("register", name, name, nil, &err)
+	if err := fs.lock(); err != nil {
+		return err
+	}
+	defer fs.unlock(&err)
+	if err := fs.before(call); err != nil {
+		return err
 	}
 
-	if validate.ValidateLength(name, 50) {
-		return fmt.Errorf("Error: Username is must be under %d characters.", 50)
+	name = validate.Normalize(name)
+	if err := fs.validateName(entityUser, name); err != nil {
+		return err
 	}
 
 	if fs.isUserExists(name) {
-		return fmt.Errorf("Error: The %s has already existed.", name)
+		return newError(ErrAlreadyExists, "Error: The %s has already existed.", name)
 	}
 
-	fs.Users[strings.ToLower(name)] = &User{
-		Name:    name,
-		Folders: make(map[string]*Folder),
+	user := &User{
+		Name:     name,
+		Folders:  make(map[string]*Folder),
+		caseMode: fs.caseMode,
 	}
+	fs.Users[userKey(name)] = user
+	fs.totals.users.Add(1)
+	fs.recordChange("register "+name, func() bool {
+		// Folders created since would be lost
+		if !fs.hasUser(user) || len(user.Folders) > 0 {
+			return false
+		}
+		delete(fs.Users, userKey(name))
+		fs.totals.users.Add(-1)
+		return true
+	}, func() bool {
+		if fs.isUserExists(name) {
+			return false
+		}
+		fs.Users[userKey(name)] = user
+		fs.totals.users.Add(1)
+		return true
+	})
 	return nil
 }
 
+// Usernames returns the sorted names of the registered users
+func (fs *FileSystem) Usernames() []string {
+	if err := fs.rlock(); err != nil {
+		return nil
+	}
+	defer fs.runlock()
+
+	names := make([]string, 0, len(fs.Users))
+	for _, user := range fs.Users {
+		names = append(names, user.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FolderNames returns the sorted names of the user's folders. Like Usernames it only
+// reads, so no hooks, metrics or logs observe it, which suits shell completion.
+func (fs *FileSystem) FolderNames(username string) []string {
+	if err := fs.rlock(); err != nil {
+		return nil
+	}
+	defer fs.runlock()
+
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil
+	}
+	names := make([]string, 0, len(user.Folders))
+	for _, folder := range user.Folders {
+		names = append(names, folder.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FileNames returns the sorted names of the files of the user's folder, unobserved like FolderNames
+func (fs *FileSystem) FileNames(username string, foldername string) []string {
+	if err := fs.rlock(); err != nil {
+		return nil
+	}
+	defer fs.runlock()
+
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil
+	}
+	folder := user.getFolderByName(foldername)
+	if folder == nil {
+		return nil
+	}
+	names := make([]string, 0, len(folder.Files))
+	for _, file := range folder.Files {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// userKey returns the key of the user in the Users map, names differing only by case
+// or normalization being the same user
+func userKey(name string) string {
+	return strings.ToLower(validate.Normalize(name))
+}
+
 // check if the user exists
 func (fs *FileSystem) isUserExists(username string) bool {
-	_, ok := fs.Users[strings.ToLower(username)]
+	_, ok := fs.Users[userKey(username)]
 	return ok
 }
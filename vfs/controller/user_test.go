@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +29,80 @@ func TestRegister(t *testing.T) {
 		t.Errorf("Expected an error but got nil")
 	}
 }
+
+func TestRegisterUnicode(t *testing.T) {
+	fs := NewFileSystem()
+
+	// Fifty CJK characters are fifty characters, not 150 bytes
+	if err := fs.Register(strings.Repeat("名", 50)); err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+	if err := fs.Register(strings.Repeat("名", 51)); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	// The composed and decomposed forms are the same user
+	if err := fs.Register("caf\u00e9"); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if err := fs.Register("cafe\u0301"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.CreateFolder("cafe\u0301", "r\u00e9sum\u00e9", ""); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	if err := fs.CreateFolder("caf\u00e9", "re\u0301sume\u0301", ""); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.CreateFile("caf\u00e9", "re\u0301sume\u0301", "file.txt", ""); err != nil {
+		t.Errorf("Expected no error but got '%s'", err.Error())
+	}
+
+	// Invisible characters are rejected
+	if err := fs.Register("admin\u200b"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if err := fs.Register("txt.exe\u202e"); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
+
+func TestNames(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	for _, name := range []string{"b_folder", "a_folder"} {
+		if err := fs.CreateFolder("test_user", name, ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+	for _, name := range []string{"b.txt", "a[1].txt"} {
+		if err := fs.CreateFile("test_user", "a_folder", name, ""); err != nil {
+			t.Fatalf("Failed to create file: %s", err)
+		}
+	}
+
+	// No hook observes the names
+	observed := 0
+	defer fs.OnBefore("", 0, func(call Call) error {
+		observed++
+		return nil
+	})()
+
+	if got := fs.FolderNames("test_user"); !reflect.DeepEqual(got, []string{"a_folder", "b_folder"}) {
+		t.Errorf("Expected the two folders but got %v", got)
+	}
+	if got := fs.FileNames("test_user", "a_folder"); !reflect.DeepEqual(got, []string{"a[1].txt", "b.txt"}) {
+		t.Errorf("Expected the two files but got %v", got)
+	}
+	if got := fs.FileNames("test_user", "non_existent_folder"); got != nil {
+		t.Errorf("Expected no files but got %v", got)
+	}
+	if got := fs.FolderNames("non_existent_user"); got != nil {
+		t.Errorf("Expected no folders but got %v", got)
+	}
+	if observed != 0 {
+		t.Errorf("Expected no observed calls but got %d", observed)
+	}
+}
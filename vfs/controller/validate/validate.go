@@ -1,16 +1,55 @@
 package validate
 
 import (
-	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
+// DefaultInvalidChars are the characters names cannot contain by default
+const DefaultInvalidChars = `\/:*?"<>|`
+
+// ValidateNoInvalidChars reports whether the name contains one of DefaultInvalidChars,
+// whitespace, a control or an invisible character, or is not valid UTF-8
 func ValidateNoInvalidChars(name string) bool {
-	regex := `[\\/:*?"<>|\s]`
+	return ValidateChars(name, DefaultInvalidChars, nil)
+}
+
+// ValidateChars reports whether the name contains one of the invalid characters,
+// a character outside the allowed classes when any are given, '/', whitespace, a control
+// or an invisible character, or is not valid UTF-8. '/' separates the names in paths
+// and ids, so it is invalid whatever invalid holds.
+func ValidateChars(name string, invalid string, allowed []*unicode.RangeTable) bool {
+	if strings.ContainsAny(name, invalid) || strings.ContainsRune(name, '/') || !utf8.ValidString(name) {
+		return true
+	}
 
-	match, _ := regexp.MatchString(regex, name)
-	return match
+	for _, r := range name {
+		if unicode.IsControl(r) || unicode.IsSpace(r) || isInvisible(r) {
+			return true
+		}
+		if len(allowed) > 0 && !unicode.In(r, allowed...) {
+			return true
+		}
+	}
+	return false
 }
 
+// ValidateLength reports whether the name has more than length characters
 func ValidateLength(name string, length int) bool {
-	return len(name) > length
+	return utf8.RuneCountInString(name) > length
+}
+
+// Normalize returns the name in Unicode normalization form C, so that names
+// looking the same are stored and looked up the same way
+func Normalize(name string) string {
+	return norm.NFC.String(name)
+}
+
+// isInvisible reports whether the rune is a format character, such as the bidi
+// controls and the zero-width characters, which make different names look the same
+func isInvisible(r rune) bool {
+	return unicode.Is(unicode.Cf, r)
 }
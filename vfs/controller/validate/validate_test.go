@@ -2,6 +2,7 @@ package validate
 
 import (
 	"testing"
+	"unicode"
 )
 
 func TestValidateNoInvalidChars(t *testing.T) {
@@ -65,6 +66,106 @@ func TestValidateNoInvalidChars(t *testing.T) {
 			input:    "my|project",
 			expected: true,
 		},
+		{
+			name:     "valid name with CJK characters",
+			input:    "我的專案",
+			expected: false,
+		},
+		{
+			name:     "valid name with accents",
+			input:    "café",
+			expected: false,
+		},
+		{
+			name:     "valid name with emoji",
+			input:    "project_🚀",
+			expected: false,
+		},
+		{
+			name:     "invalid name with tab",
+			input:    "my\tproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with NUL",
+			input:    "my\x00project",
+			expected: true,
+		},
+		{
+			name:     "invalid name with escape",
+			input:    "my\x1bproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with DEL",
+			input:    "my\x7fproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with C1 control",
+			input:    "my\u0085project",
+			expected: true,
+		},
+		{
+			name:     "invalid name with no-break space",
+			input:    "my\u00a0project",
+			expected: true,
+		},
+		{
+			name:     "invalid name with ideographic space",
+			input:    "my\u3000project",
+			expected: true,
+		},
+		{
+			name:     "invalid name with right-to-left override",
+			input:    "my\u202eproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with left-to-right embedding",
+			input:    "my\u202aproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with first strong isolate",
+			input:    "my\u2068project",
+			expected: true,
+		},
+		{
+			name:     "invalid name with right-to-left mark",
+			input:    "my\u200fproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with arabic letter mark",
+			input:    "my\u061cproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with zero-width space",
+			input:    "my\u200bproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with zero-width joiner",
+			input:    "my\u200dproject",
+			expected: true,
+		},
+		{
+			name:     "invalid name with word joiner",
+			input:    "my\u2060project",
+			expected: true,
+		},
+		{
+			name:     "invalid name with byte order mark",
+			input:    "\ufeffmy_project",
+			expected: true,
+		},
+		{
+			name:     "invalid name with invalid UTF-8",
+			input:    "my\xffproject",
+			expected: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -96,6 +197,30 @@ func TestValidateLength(t *testing.T) {
 			length:   20,
 			expected: false,
 		},
+		{
+			name:     "CJK name at the limit",
+			input:    "專案專案專",
+			length:   5,
+			expected: false,
+		},
+		{
+			name:     "CJK name over the limit",
+			input:    "專案專案專案",
+			length:   5,
+			expected: true,
+		},
+		{
+			name:     "decomposed name counts the combining marks",
+			input:    "cafe\u0301",
+			length:   4,
+			expected: true,
+		},
+		{
+			name:     "emoji counts as one character",
+			input:    "🚀",
+			length:   1,
+			expected: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -107,3 +232,120 @@ func TestValidateLength(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "ASCII name is unchanged",
+			input:    "my_project",
+			expected: "my_project",
+		},
+		{
+			name:     "composed name is unchanged",
+			input:    "caf\u00e9",
+			expected: "caf\u00e9",
+		},
+		{
+			name:     "decomposed name is composed",
+			input:    "cafe\u0301",
+			expected: "caf\u00e9",
+		},
+		{
+			name:     "hangul jamo are composed",
+			input:    "\u1112\u1161\u11ab",
+			expected: "\ud55c",
+		},
+		{
+			name:     "CJK name is unchanged",
+			input:    "我的專案",
+			expected: "我的專案",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Normalize(test.input)
+			if result != test.expected {
+				t.Errorf("Expected %q but got %q for input %q", test.expected, result, test.input)
+			}
+		})
+	}
+}
+
+func TestValidateChars(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		invalid  string
+		allowed  []*unicode.RangeTable
+		expected bool
+	}{
+		{
+			name:     "no invalid characters",
+			input:    "my:project",
+			invalid:  "",
+			expected: false,
+		},
+		{
+			name:     "custom invalid character",
+			input:    "my.project",
+			invalid:  ".",
+			expected: true,
+		},
+		{
+			name:     "whitespace is always invalid",
+			input:    "my project",
+			invalid:  "",
+			expected: true,
+		},
+		{
+			name:     "slash is always invalid",
+			input:    "my/project",
+			invalid:  "",
+			expected: true,
+		},
+		{
+			name:     "zero-width space is always invalid",
+			input:    "my\u200bproject",
+			invalid:  "",
+			expected: true,
+		},
+		{
+			name:     "allowed classes",
+			input:    "my_project1",
+			allowed:  []*unicode.RangeTable{unicode.Letter, unicode.Digit, unicode.Pc},
+			expected: false,
+		},
+		{
+			name:     "outside the allowed classes",
+			input:    "my-project",
+			allowed:  []*unicode.RangeTable{unicode.Letter, unicode.Digit, unicode.Pc},
+			expected: true,
+		},
+		{
+			name:     "allowed script",
+			input:    "我的專案",
+			allowed:  []*unicode.RangeTable{unicode.Han},
+			expected: false,
+		},
+		{
+			name:     "outside the allowed script",
+			input:    "我的project",
+			allowed:  []*unicode.RangeTable{unicode.Han},
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := ValidateChars(test.input, test.invalid, test.allowed)
+			if result != test.expected {
+				t.Errorf("Expected %v but got %v for input %q", test.expected, result, test.input)
+			}
+		})
+	}
+}
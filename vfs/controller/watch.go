@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+)
+
+// WatchBufferSize is the number of events a Watcher buffers before it starts dropping them.
+// One more slot is kept free for the EventOverflow reporting the first dropped event.
+const WatchBufferSize = 64
+
+// EventType is the kind of change reported to a Watcher
+type EventType int
+
+const (
+	EventCreated EventType = iota + 1
+	EventDeleted
+	EventRenamed
+	EventDescriptionChanged
+	// EventOverflow is queued as soon as an event is dropped, right after the events buffered before it
+	EventOverflow
+)
+
+// String returns the name of the event type
+func (t EventType) String() string {
+	switch t {
+	case EventCreated:
+		return "created"
+	case EventDeleted:
+		return "deleted"
+	case EventRenamed:
+		return "renamed"
+	case EventDescriptionChanged:
+		return "description-changed"
+	case EventOverflow:
+		return "overflow"
+	}
+	return "unknown"
+}
+
+// Event describes a change to a folder or a file
+type Event struct {
+	Type     EventType
+	Username string
+	Folder   string
+	// File is empty when the event is about the folder itself
+	File string
+	// OldName is the previous folder or file name of an EventRenamed
+	OldName string
+	// Dropped is the number of events lost before an EventOverflow.
+	// Events lost while an EventOverflow waits in a full buffer are reported by the next one.
+	Dropped int
+	Time    time.Time
+}
+
+// String formats the event for display
+func (e Event) String() string {
+	return e.Format(DefaultTimeFormat, nil)
+}
+
+// Format formats the event for display with the time layout and zone of a listing.
+// A nil location keeps the location of the event time.
+func (e Event) Format(layout string, location *time.Location) string {
+	t := e.Time
+	if location != nil {
+		t = t.In(location)
+	}
+	if e.Type == EventOverflow {
+		return fmt.Sprintf("%s %s %d events dropped", t.Format(layout), e.Type, e.Dropped)
+	}
+
+	path := e.Username + "/" + e.Folder
+	if e.File != "" {
+		path += "/" + e.File
+	}
+	if e.Type == EventRenamed {
+		return fmt.Sprintf("%s %s %s (was %s)", t.Format(layout), e.Type, path, e.OldName)
+	}
+	return fmt.Sprintf("%s %s %s", t.Format(layout), e.Type, path)
+}
+
+// Watcher is a subscription created by Watch
+type Watcher struct {
+	// Events is closed when the watcher is closed
+	Events <-chan Event
+
+	fs      *FileSystem
+	events  chan Event
+	user    *User
+	folder  *Folder
+	dropped int
+}
+
+// Watch subscribes to changes of the user's folders and files.
+// If foldername is empty every folder of the user is watched, otherwise only the
+// given folder is, and it keeps being watched when it is renamed.
+func (fs *FileSystem) Watch(username string, foldername string) (w *Watcher, err error) {
+	call := Call{Operation: "watch", User: username, Folder: foldername}
+	defer fs.observe(call, fs.clock.Now(), &err)
+	if err := fs.rlock(); err != nil {
+		return nil, err
+	}
+	defer fs.runlock()
+	if err := fs.before(call); err != nil {
+		return nil, err
+	}
+	user := fs.getUserByUsername(username)
+	if user == nil {
+		return nil, newError(ErrNotFound, "Error: The %s doesn't exist.", username)
+	}
+
+	var folder *Folder
+	if foldername != "" {
+		folder = user.getFolderByName(foldername)
+		if folder == nil {
+			return nil, newError(ErrNotFound, "Error: The %s doesn't exist.", foldername)
+		}
+	}
+
+	events := make(chan Event, WatchBufferSize+1)
+	w = &Watcher{
+		Events: events,
+		fs:     fs,
+		events: events,
+		user:   user,
+		folder: folder,
+	}
+
+	fs.watchMu.Lock()
+	defer fs.watchMu.Unlock()
+	if fs.watchers == nil {
+		fs.watchers = make(map[*Watcher]struct{})
+	}
+	fs.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// Close ends the subscription and closes the Events channel
+func (w *Watcher) Close() {
+	w.fs.watchMu.Lock()
+	defer w.fs.watchMu.Unlock()
+	if _, ok := w.fs.watchers[w]; !ok {
+		return
+	}
+	delete(w.fs.watchers, w)
+	close(w.events)
+}
+
+// notify delivers the event to every watcher interested in the folder
+func (fs *FileSystem) notify(user *User, folder *Folder, event Event) {
+	event.Username = user.Name
+	event.Time = fs.clock.Now()
+
+	// Events of a transaction are held back until it is committed
+	if fs.tx != nil && !fs.tx.done {
+		fs.tx.events = append(fs.tx.events, pendingEvent{user: user, folder: folder, event: event})
+		return
+	}
+	fs.deliver(user, folder, event)
+}
+
+// deliver sends the event to every watcher interested in the folder
+func (fs *FileSystem) deliver(user *User, folder *Folder, event Event) {
+	fs.watchMu.Lock()
+	defer fs.watchMu.Unlock()
+	for w := range fs.watchers {
+		if w.user != user || (w.folder != nil && w.folder != folder) {
+			continue
+		}
+		w.send(event)
+	}
+}
+
+// send delivers the event without blocking, reporting dropped events with an EventOverflow.
+// Regular events only use the first WatchBufferSize slots so the overflow of a full
+// buffer can always be queued at once, even if no other event follows.
+func (w *Watcher) send(event Event) {
+	overflow := Event{Type: EventOverflow, Username: w.user.Name, Dropped: w.dropped, Time: event.Time}
+	if w.dropped > 0 {
+		select {
+		case w.events <- overflow:
+			w.dropped = 0
+		default:
+			w.dropped++
+			return
+		}
+	}
+
+	// deliver is the only sender, the buffer can only shrink between the check and the send
+	if len(w.events) < WatchBufferSize {
+		w.events <- event
+		return
+	}
+	overflow.Dropped = 1
+	select {
+	case w.events <- overflow:
+	default:
+		w.dropped++
+	}
+}
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	fs := NewFileSystem()
+
+	// Test watching a user that doesn't exist
+	_, err := fs.Watch("test_user", "")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	err = fs.Register("test_user")
+	if err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+
+	// Test watching a folder that doesn't exist
+	_, err = fs.Watch("test_user", "test_folder")
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+
+	w, err := fs.Watch("test_user", "")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	defer w.Close()
+
+	if err := fs.CreateFolder("test_user", "test_folder", "test_description"); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "test_folder", "test_file.txt", "test_description"); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+	if err := fs.RenameFolder("test_user", "test_folder", "new_test_folder"); err != nil {
+		t.Fatalf("Failed to rename folder: %s", err)
+	}
+	if err := fs.DeleteFile("test_user", "new_test_folder", "test_file.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %s", err)
+	}
+	if err := fs.DeleteFolder("test_user", "new_test_folder"); err != nil {
+		t.Fatalf("Failed to delete folder: %s", err)
+	}
+
+	expected := []Event{
+		{Type: EventCreated, Username: "test_user", Folder: "test_folder"},
+		{Type: EventCreated, Username: "test_user", Folder: "test_folder", File: "test_file.txt"},
+		{Type: EventRenamed, Username: "test_user", Folder: "new_test_folder", OldName: "test_folder"},
+		{Type: EventDeleted, Username: "test_user", Folder: "new_test_folder", File: "test_file.txt"},
+		{Type: EventDeleted, Username: "test_user", Folder: "new_test_folder"},
+	}
+	for _, want := range expected {
+		got := <-w.Events
+		got.Time = want.Time
+		if got != want {
+			t.Errorf("Expected %+v but got %+v", want, got)
+		}
+	}
+}
+
+func TestWatchFolder(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "folder1", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "folder2", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	w, err := fs.Watch("test_user", "folder1")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	defer w.Close()
+
+	// Changes to other folders are not reported
+	if err := fs.CreateFile("test_user", "folder2", "file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	// The folder is still watched after it is renamed
+	if err := fs.RenameFolder("test_user", "folder1", "folder3"); err != nil {
+		t.Fatalf("Failed to rename folder: %s", err)
+	}
+	if err := fs.CreateFile("test_user", "folder3", "file.txt", ""); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	if got := <-w.Events; got.Type != EventRenamed || got.Folder != "folder3" {
+		t.Errorf("Expected a rename of folder3 but got %+v", got)
+	}
+	if got := <-w.Events; got.Type != EventCreated || got.Folder != "folder3" || got.File != "file.txt" {
+		t.Errorf("Expected the creation of folder3/file.txt but got %+v", got)
+	}
+	select {
+	case got := <-w.Events:
+		t.Errorf("Expected no more events but got %+v", got)
+	default:
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	if err := fs.CreateFolder("test_user", "test_folder", ""); err != nil {
+		t.Fatalf("Failed to create folder: %s", err)
+	}
+
+	w, err := fs.Watch("test_user", "test_folder")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+
+	// Fill the buffer and drop two more events
+	for i := 0; i < WatchBufferSize+2; i++ {
+		fs.notify(fs.getUserByUsername("test_user"), w.folder, Event{Type: EventCreated, Folder: "test_folder"})
+	}
+	for i := 0; i < WatchBufferSize; i++ {
+		if got := <-w.Events; got.Type != EventCreated {
+			t.Fatalf("Expected a creation but got %+v", got)
+		}
+	}
+
+	// The first drop is reported right after the buffered events
+	if got := <-w.Events; got.Type != EventOverflow || got.Dropped != 1 {
+		t.Errorf("Expected an overflow of 1 event but got %+v", got)
+	}
+
+	// The drop that happened while the overflow was queued precedes the next event
+	fs.notify(fs.getUserByUsername("test_user"), w.folder, Event{Type: EventDeleted, Folder: "test_folder"})
+	if got := <-w.Events; got.Type != EventOverflow || got.Dropped != 1 {
+		t.Errorf("Expected an overflow of 1 event but got %+v", got)
+	}
+	if got := <-w.Events; got.Type != EventDeleted {
+		t.Errorf("Expected a deletion but got %+v", got)
+	}
+
+	// Closing the watcher closes the channel
+	w.Close()
+	if _, ok := <-w.Events; ok {
+		t.Errorf("Expected the channel to be closed")
+	}
+	w.Close()
+}
+
+func TestWatchOverflowWithoutFurtherEvents(t *testing.T) {
+	fs := NewFileSystem()
+	if err := fs.Register("test_user"); err != nil {
+		t.Fatalf("Failed to register user: %s", err)
+	}
+	w, err := fs.Watch("test_user", "")
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err.Error())
+	}
+	defer w.Close()
+
+	for i := 0; i < WatchBufferSize+5; i++ {
+		if err := fs.CreateFolder("test_user", fmt.Sprintf("folder%d", i), ""); err != nil {
+			t.Fatalf("Failed to create folder: %s", err)
+		}
+	}
+
+	// Draining the buffer ends with the overflow, no later change is needed to see it
+	for i := 0; i < WatchBufferSize; i++ {
+		if got := <-w.Events; got.Type != EventCreated {
+			t.Fatalf("Expected a creation but got %+v", got)
+		}
+	}
+	select {
+	case got := <-w.Events:
+		if got.Type != EventOverflow {
+			t.Errorf("Expected an overflow but got %+v", got)
+		}
+	default:
+		t.Errorf("Expected an overflow after draining the buffer")
+	}
+}
@@ -0,0 +1,290 @@
+// Package metrics keeps counters, gauges and histograms and publishes them
+// in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets in seconds, fit for in-memory calls
+var DefaultBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// Registry holds the metrics of a program. It is an http.Handler serving them.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// metric is a family of series sharing a name
+type metric interface {
+	write(w *bufio.Writer, name string)
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic("metrics: " + name + " is already registered")
+	}
+	r.metrics[name] = m
+}
+
+// WriteText writes every metric, sorted by name, in the text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for i, m := range metrics {
+		m.write(bw, names[i])
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics, as a /metrics endpoint does
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+// labels names the labels of a family and keeps its series by label values
+type labels struct {
+	names []string
+}
+
+func (l labels) key(values []string) string {
+	if len(values) != len(l.names) {
+		panic(fmt.Sprintf("metrics: %d label values given for %d labels", len(values), len(l.names)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// format returns the label set of a series, with an extra label when extra is not empty
+func (l labels) format(values []string, extra ...string) string {
+	var parts []string
+	for i, name := range l.names {
+		parts = append(parts, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, helpEscaper.Replace(help), name, kind)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of the series in a stable order
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a family of values that only go up, such as calls
+type Counter struct {
+	help   string
+	labels labels
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{help: help, labels: labels{labelNames}, series: make(map[string]*counterSeries)}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series with the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series with the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: a counter cannot decrease")
+	}
+	key := c.labels.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the series with the label values, 0 when it was never added to
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.labels.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w *bufio.Writer, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, name, c.help, "counter")
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", name, c.labels.format(s.values), formatValue(s.value))
+	}
+}
+
+// gaugeFunc is a gauge read when the metrics are written
+type gaugeFunc struct {
+	help string
+	fn   func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by fn, which must be safe
+// for concurrent use
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &gaugeFunc{help: help, fn: fn})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer, name string) {
+	writeHeader(w, name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(g.fn()))
+}
+
+// Histogram is a family of distributions, such as latencies, counted in buckets
+type Histogram struct {
+	help    string
+	labels  labels
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	// counts holds the observations per bucket, not cumulated, the last one is +Inf
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given upper bounds, which are sorted,
+// and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{help: help, labels: labels{labelNames}, buckets: sorted, series: make(map[string]*histogramSeries)}
+	r.register(name, h)
+	return h
+}
+
+// Observe adds v to the series with the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.labels.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, v)]++
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations of the series with the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.labels.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, name, h.help, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.labels.format(s.values, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.labels.format(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, h.labels.format(s.values), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, h.labels.format(s.values), s.count)
+	}
+}
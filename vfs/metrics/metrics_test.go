@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	calls := r.NewCounter("calls_total", "Calls by operation.", "operation", "result")
+	r.NewGaugeFunc("users", "Registered users.", func() float64 { return 3 })
+	latency := r.NewHistogram("latency_seconds", "Call latency.", []float64{1, 0.1}, "operation")
+
+	calls.Inc("read", "ok")
+	calls.Inc("read", "ok")
+	calls.Add(1, "write", `bad "quote"`)
+	latency.Observe(0.05, "read")
+	latency.Observe(0.1, "read")
+	latency.Observe(2, "read")
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	expected := `# HELP calls_total Calls by operation.
+# TYPE calls_total counter
+calls_total{operation="read",result="ok"} 2
+calls_total{operation="write",result="bad \"quote\""} 1
+# HELP latency_seconds Call latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{operation="read",le="0.1"} 2
+latency_seconds_bucket{operation="read",le="1"} 2
+latency_seconds_bucket{operation="read",le="+Inf"} 3
+latency_seconds_sum{operation="read"} 2.15
+latency_seconds_count{operation="read"} 3
+# HELP users Registered users.
+# TYPE users gauge
+users 3
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\nbut got:\n%s", expected, out.String())
+	}
+
+	if calls.Value("read", "ok") != 2 || calls.Value("read", "error") != 0 {
+		t.Errorf("Expected 2 and 0 but got %v and %v", calls.Value("read", "ok"), calls.Value("read", "error"))
+	}
+	if latency.Count("read") != 3 {
+		t.Errorf("Expected 3 observations but got %d", latency.Count("read"))
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("calls_total", "Calls.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Expected the content type %s but got %s", ContentType, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "calls_total 1\n") {
+		t.Errorf("Expected the counter in '%s'", rec.Body.String())
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("calls_total", "Calls.")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic")
+		}
+	}()
+	r.NewCounter("calls_total", "Calls.")
+}
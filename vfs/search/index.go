@@ -0,0 +1,214 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Result is a document matching a query
+type Result struct {
+	ID    string
+	Score float64
+}
+
+// Index is an inverted index of documents identified by string IDs
+type Index struct {
+	mu sync.RWMutex
+	// terms maps a term to the positions where it occurs in each document
+	terms map[string]map[string][]int
+	// docs holds the tokens of each document
+	docs        map[string][]string
+	totalTokens int
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		terms: make(map[string]map[string][]int),
+		docs:  make(map[string][]string),
+	}
+}
+
+// Tokenize splits text into lower case words of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Add indexes the text of a document, replacing what was indexed for it before
+func (ix *Index) Add(id string, text string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+	tokens := Tokenize(text)
+	ix.docs[id] = tokens
+	ix.totalTokens += len(tokens)
+	for position, token := range tokens {
+		postings := ix.terms[token]
+		if postings == nil {
+			postings = make(map[string][]int)
+			ix.terms[token] = postings
+		}
+		postings[id] = append(postings[id], position)
+	}
+}
+
+// Remove drops a document from the index
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	tokens, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, token := range tokens {
+		postings := ix.terms[token]
+		delete(postings, id)
+		if len(postings) == 0 {
+			delete(ix.terms, token)
+		}
+	}
+	ix.totalTokens -= len(tokens)
+	delete(ix.docs, id)
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search returns the documents whose ID starts with prefix and that contain every
+// word and "quoted phrase" of the query, best matches first
+func (ix *Index) Search(query string, prefix string) ([]Result, error) {
+	clauses, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Count the occurrences of every clause in every candidate document
+	var candidates map[string][]int
+	for i, clause := range clauses {
+		matches := ix.occurrences(clause, prefix)
+		next := make(map[string][]int, len(matches))
+		for id, count := range matches {
+			if i == 0 {
+				next[id] = make([]int, len(clauses))
+			} else if counts, ok := candidates[id]; ok {
+				next[id] = counts
+			} else {
+				continue
+			}
+			next[id][i] = count
+		}
+		candidates = next
+	}
+
+	results := make([]Result, 0, len(candidates))
+	avgLength := float64(ix.totalTokens) / float64(len(ix.docs))
+	for id, counts := range candidates {
+		length := float64(len(ix.docs[id]))
+		score := 0.0
+		for i, clause := range clauses {
+			tf := float64(counts[i])
+			score += ix.idf(clause) * tf * (k1 + 1) / (tf + k1*(1-b+b*length/avgLength))
+		}
+		results = append(results, Result{ID: id, Score: score})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results, nil
+}
+
+// occurrences counts how often the phrase occurs in each document under the prefix
+func (ix *Index) occurrences(phrase []string, prefix string) map[string]int {
+	counts := make(map[string]int)
+	for id, positions := range ix.terms[phrase[0]] {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		for _, start := range positions {
+			if ix.phraseAt(id, phrase, start) {
+				counts[id]++
+			}
+		}
+	}
+	return counts
+}
+
+// phraseAt reports whether the phrase occurs in the document at the position
+func (ix *Index) phraseAt(id string, phrase []string, start int) bool {
+	tokens := ix.docs[id]
+	if start+len(phrase) > len(tokens) {
+		return false
+	}
+	for i, term := range phrase {
+		if tokens[start+i] != term {
+			return false
+		}
+	}
+	return true
+}
+
+// idf weighs a phrase by the rarest of its terms
+func (ix *Index) idf(phrase []string) float64 {
+	df := len(ix.docs)
+	for _, term := range phrase {
+		if n := len(ix.terms[term]); n < df {
+			df = n
+		}
+	}
+	n := float64(len(ix.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// parseQuery splits a query into single word clauses and "quoted phrase" clauses
+func parseQuery(query string) ([][]string, error) {
+	if strings.Count(query, `"`)%2 != 0 {
+		return nil, fmt.Errorf("Error: Unterminated phrase in %s.", query)
+	}
+
+	var clauses [][]string
+	for i, part := range strings.Split(query, `"`) {
+		tokens := Tokenize(part)
+		if i%2 == 1 {
+			if len(tokens) > 0 {
+				clauses = append(clauses, tokens)
+			}
+			continue
+		}
+		for _, token := range tokens {
+			clauses = append(clauses, []string{token})
+		}
+	}
+
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("Error: The search query is empty.")
+	}
+	return clauses, nil
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "words", input: "Invoice 2023", expected: []string{"invoice", "2023"}},
+		{name: "punctuation", input: "invoice-2023.pdf, paid!", expected: []string{"invoice", "2023", "pdf", "paid"}},
+		{name: "unicode", input: "Café 発票", expected: []string{"café", "発票"}},
+		{name: "empty", input: " - ", expected: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Tokenize(test.input)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Expected %v but got %v for input %s", test.expected, result, test.input)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("alice/docs/a.txt", "invoice 2023 for the paid order")
+	ix.Add("alice/docs/b.txt", "invoice invoice invoice 2023")
+	ix.Add("alice/docs/c.txt", "2023 invoice draft")
+	ix.Add("bob/docs/d.txt", "invoice 2023")
+	ix.Add("alice/notes", "meeting notes")
+
+	tests := []struct {
+		name     string
+		query    string
+		prefix   string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "all words", query: "invoice 2023", prefix: "alice/", expected: []string{"alice/docs/b.txt", "alice/docs/c.txt", "alice/docs/a.txt"}},
+		{name: "phrase", query: `"invoice 2023"`, prefix: "alice/", expected: []string{"alice/docs/b.txt", "alice/docs/a.txt"}},
+		{name: "phrase and word", query: `"invoice 2023" paid`, prefix: "", expected: []string{"alice/docs/a.txt"}},
+		{name: "scoped to user", query: "invoice", prefix: "bob/", expected: []string{"bob/docs/d.txt"}},
+		{name: "case insensitive", query: "MEETING", prefix: "", expected: []string{"alice/notes"}},
+		{name: "no match", query: "receipt", prefix: "", expected: []string{}},
+		{name: "empty query", query: " ", wantErr: true},
+		{name: "unterminated phrase", query: `"invoice`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := ix.Search(test.query, test.prefix)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expected error %v but got '%v'", test.wantErr, err)
+			}
+			if test.wantErr {
+				return
+			}
+			ids := []string{}
+			for _, result := range results {
+				ids = append(ids, result.ID)
+			}
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Errorf("Expected %v but got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestAddAndRemove(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("alice/docs/a.txt", "old content")
+	ix.Add("alice/docs/a.txt", "new content")
+
+	if results, _ := ix.Search("old", ""); len(results) != 0 {
+		t.Errorf("Expected replaced content not to match but got %v", results)
+	}
+	if results, _ := ix.Search("new", ""); len(results) != 1 {
+		t.Errorf("Expected 1 result but got %v", results)
+	}
+
+	ix.Remove("alice/docs/a.txt")
+	if ix.Len() != 0 || len(ix.terms) != 0 || ix.totalTokens != 0 {
+		t.Errorf("Expected an empty index but got %d documents and %d terms", ix.Len(), len(ix.terms))
+	}
+	ix.Remove("alice/docs/a.txt")
+}